@@ -0,0 +1,532 @@
+package counter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// closeRetryInterval CloseContext 重试 flush 的间隔
+const closeRetryInterval = 100 * time.Millisecond
+
+// LossPolicy 决定 flush 失败时如何处理未写入后端的增量
+type LossPolicy int
+
+const (
+	// RetryOnError 因暂时错误（网络、超时等）失败的增量合并回本地，下次 flush 时重试；
+	// 后端明确拒绝的增量（如 redis 返回 WRONGTYPE）重试也不会成功，不再重试。
+	// 后台 flush 中被拒绝的增量与 Close/CloseContext 最后仍未写入的增量一起通过 *FlushError 返回
+	RetryOnError LossPolicy = iota
+	// DropOnError 失败的增量直接丢弃
+	DropOnError
+)
+
+// FlushError 由 Close/CloseContext 返回，Unflushed 为关闭时未能写入后端的增量，
+// 调用方可自行持久化或重放
+type FlushError struct {
+	Unflushed []IncrOp
+	Err       error
+}
+
+func (e *FlushError) Error() string {
+	return fmt.Sprintf("counter: buffered counter closed with %d deltas unflushed: %v", len(e.Unflushed), e.Err)
+}
+
+func (e *FlushError) Unwrap() error {
+	return e.Err
+}
+
+// BufferedCounter 在本地聚合 Incr/IncrWithGroup 的增量，按 flushInterval 或
+// 待写入数量达到 maxPending 时通过 IncrBatch 批量写入后端。
+//
+// 进程异常退出时未 flush 的增量会丢失；正常退出需调用 Close。
+// Get 类读取为后端值加上本地未写入的增量，flush 进行中可能短暂重复计入正在写入的增量；
+// Incr 返回值为后端值加上本地增量：后端值取自最近一次 flush 的结果，key 在上一周期内没有写入时
+// 先从后端读取一次，其他进程在此期间对后端的写入要到下次 flush 后才反映在返回值中。
+// IncrWindow/GetWindow/Renew/TTL/TopFromGroup 不做缓冲，直接访问后端。
+type BufferedCounter struct {
+	backend    Counter
+	maxPending int
+
+	mux      sync.Mutex
+	pending  map[string]map[string]*delta
+	count    int
+	flushing map[string]map[string]*delta
+	base     map[string]map[string]int64
+	policy   LossPolicy
+	closed   bool
+	// rejected 为 RetryOnError 下后台 flush 丢弃的增量，rejectErr 为其中第一个错误
+	rejected  []IncrOp
+	rejectErr error
+
+	flushMux sync.Mutex
+	notify   chan struct{}
+	done     chan struct{}
+	wg       sync.WaitGroup
+}
+
+type delta struct {
+	step int64
+	ttl  time.Duration
+}
+
+// NewBufferedCounter flushInterval 不大于 0 时不定时 flush，maxPending 不大于 0 时不按数量 flush
+func NewBufferedCounter(backend Counter, flushInterval time.Duration, maxPending int) *BufferedCounter {
+	b := &BufferedCounter{
+		backend:    backend,
+		maxPending: maxPending,
+		pending:    make(map[string]map[string]*delta),
+		notify:     make(chan struct{}, 1),
+		done:       make(chan struct{}),
+	}
+
+	b.wg.Add(1)
+	go func() {
+		defer b.wg.Done()
+
+		var tick <-chan time.Time
+		if flushInterval > 0 {
+			ticker := time.NewTicker(flushInterval)
+			defer ticker.Stop()
+			tick = ticker.C
+		}
+
+		for {
+			select {
+			case <-tick:
+				b.backgroundFlush()
+			case <-b.notify:
+				b.backgroundFlush()
+			case <-b.done:
+				return
+			}
+		}
+	}()
+	return b
+}
+
+// SetLossPolicy 设置 flush 失败时的处理策略，默认为 RetryOnError
+func (b *BufferedCounter) SetLossPolicy(policy LossPolicy) {
+	b.mux.Lock()
+	b.policy = policy
+	b.mux.Unlock()
+}
+
+func (b *BufferedCounter) Incr(ctx context.Context, key string, step int64, ttl time.Duration) (int64, error) {
+	return b.incr(ctx, key, "", step, ttl)
+}
+
+func (b *BufferedCounter) Get(ctx context.Context, key string) (int64, error) {
+	n, err := b.backend.Get(ctx, key)
+	if err != nil {
+		return 0, err
+	}
+
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	return n + b.unflushed(key, ""), nil
+}
+
+func (b *BufferedCounter) IncrWithGroup(ctx context.Context, group, member string, step int64, ttl time.Duration) (int64, error) {
+	if member == "" {
+		return 0, fmt.Errorf("counter: empty member of group %q", group)
+	}
+	return b.incr(ctx, group, member, step, ttl)
+}
+
+func (b *BufferedCounter) GetFromGroup(ctx context.Context, group, member string) (int64, error) {
+	n, err := b.backend.GetFromGroup(ctx, group, member)
+	if err != nil {
+		return 0, err
+	}
+
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	return n + b.unflushed(group, member), nil
+}
+
+func (b *BufferedCounter) MGetFromGroup(ctx context.Context, group string, members ...string) (map[string]int64, error) {
+	result, err := b.backend.MGetFromGroup(ctx, group, members...)
+	if err != nil {
+		return nil, err
+	}
+
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	for _, member := range members {
+		result[member] += b.unflushed(group, member)
+	}
+	return result, nil
+}
+
+func (b *BufferedCounter) GetAllFromGroup(ctx context.Context, group string) (map[string]int64, error) {
+	result, err := b.backend.GetAllFromGroup(ctx, group)
+	if err != nil {
+		return nil, err
+	}
+
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	for _, m := range []map[string]map[string]*delta{b.pending, b.flushing} {
+		for member, d := range m[group] {
+			result[member] += d.step
+		}
+	}
+	return result, nil
+}
+
+func (b *BufferedCounter) Renew(ctx context.Context, keyOrGroup string, ttl time.Duration) (bool, error) {
+	return b.backend.Renew(ctx, keyOrGroup, ttl)
+}
+
+func (b *BufferedCounter) TTL(ctx context.Context, keyOrGroup string) (time.Duration, error) {
+	return b.backend.TTL(ctx, keyOrGroup)
+}
+
+func (b *BufferedCounter) Clean(ctx context.Context, keyOrGroup string) error {
+	b.flushMux.Lock()
+	defer b.flushMux.Unlock()
+
+	b.mux.Lock()
+	b.count -= len(b.pending[keyOrGroup])
+	delete(b.pending, keyOrGroup)
+	delete(b.base, keyOrGroup)
+	b.mux.Unlock()
+
+	return b.backend.Clean(ctx, keyOrGroup)
+}
+
+func (b *BufferedCounter) ResetGroup(ctx context.Context, group string, members ...string) error {
+	b.flushMux.Lock()
+	defer b.flushMux.Unlock()
+
+	b.mux.Lock()
+	for _, member := range members {
+		if _, ok := b.pending[group][member]; ok {
+			delete(b.pending[group], member)
+			b.count--
+		}
+		delete(b.base[group], member)
+	}
+	b.mux.Unlock()
+
+	return b.backend.ResetGroup(ctx, group, members...)
+}
+
+func (b *BufferedCounter) TopFromGroup(ctx context.Context, group string, k int, desc bool) ([]GroupMember, error) {
+	return b.backend.TopFromGroup(ctx, group, k, desc)
+}
+
+func (b *BufferedCounter) IncrBatch(ctx context.Context, ops []IncrOp) ([]IncrResult, error) {
+	results := make([]IncrResult, len(ops))
+	for i, op := range ops {
+		results[i].Value, results[i].Err = b.incr(ctx, op.Key, op.Member, op.Step, op.TTL)
+	}
+	return results, nil
+}
+
+func (b *BufferedCounter) IncrWindow(ctx context.Context, key string, step int64, window, resolution time.Duration) (int64, error) {
+	return b.backend.IncrWindow(ctx, key, step, window, resolution)
+}
+
+func (b *BufferedCounter) GetWindow(ctx context.Context, key string, window, resolution time.Duration) (int64, error) {
+	return b.backend.GetWindow(ctx, key, window, resolution)
+}
+
+// Flush 将本地增量写入后端，返回 IncrBatch 或第一个失败增量的错误
+func (b *BufferedCounter) Flush(ctx context.Context) error {
+	_, err := b.flush(ctx)
+	return err
+}
+
+// Close 停止后台 flush 并写入剩余增量，只尝试一次，之后的操作直接访问后端。
+// 有增量未能写入时返回 *FlushError
+func (b *BufferedCounter) Close() error {
+	return b.close(context.Background(), false)
+}
+
+// CloseContext 与 Close 相同，但在 RetryOnError 下遇到暂时错误时持续重试，直到全部写入或 ctx 结束
+func (b *BufferedCounter) CloseContext(ctx context.Context) error {
+	return b.close(ctx, true)
+}
+
+func (b *BufferedCounter) close(ctx context.Context, retry bool) error {
+	b.mux.Lock()
+	if b.closed {
+		b.mux.Unlock()
+		return nil
+	}
+	b.closed = true
+	retry = retry && b.policy == RetryOnError
+	b.mux.Unlock()
+
+	close(b.done)
+	b.wg.Wait()
+
+	b.mux.Lock()
+	unflushed, rejectErr := b.rejected, b.rejectErr
+	b.rejected, b.rejectErr = nil, nil
+	b.mux.Unlock()
+
+	var err error
+	for {
+		var dropped []IncrOp
+		dropped, err = b.flush(ctx)
+		unflushed = append(unflushed, dropped...)
+		if err == nil || !retry || b.pendingLen() == 0 {
+			break
+		}
+
+		timer := time.NewTimer(closeRetryInterval)
+		select {
+		case <-timer.C:
+			continue
+		case <-ctx.Done():
+			timer.Stop()
+		}
+		break
+	}
+
+	b.mux.Lock()
+	for key, members := range b.pending {
+		for member, d := range members {
+			unflushed = append(unflushed, IncrOp{Key: key, Member: member, Step: d.step, TTL: d.ttl})
+		}
+	}
+	b.pending = make(map[string]map[string]*delta)
+	b.count = 0
+	b.mux.Unlock()
+
+	if len(unflushed) == 0 {
+		return nil
+	}
+	if err == nil {
+		err = rejectErr
+	}
+	if err == nil {
+		err = errors.New("counter: deltas rejected by backend")
+	}
+	return &FlushError{Unflushed: unflushed, Err: err}
+}
+
+// backgroundFlush 记录 RetryOnError 下被丢弃的增量，由 Close 返回
+func (b *BufferedCounter) backgroundFlush() {
+	dropped, err := b.flush(context.Background())
+	if len(dropped) == 0 {
+		return
+	}
+
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	if b.policy != RetryOnError {
+		return
+	}
+	b.rejected = append(b.rejected, dropped...)
+	if b.rejectErr == nil {
+		b.rejectErr = err
+	}
+}
+
+func (b *BufferedCounter) pendingLen() int {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	return b.count
+}
+
+// flush 返回未合并回本地、被丢弃的增量
+func (b *BufferedCounter) flush(ctx context.Context) ([]IncrOp, error) {
+	b.flushMux.Lock()
+	defer b.flushMux.Unlock()
+
+	b.mux.Lock()
+	if b.count == 0 {
+		b.mux.Unlock()
+		return nil, nil
+	}
+	batch := b.pending
+	b.pending = make(map[string]map[string]*delta, len(batch))
+	b.count = 0
+	b.flushing = batch
+	b.mux.Unlock()
+
+	ops := make([]IncrOp, 0, len(batch))
+	for key, members := range batch {
+		for member, d := range members {
+			ops = append(ops, IncrOp{Key: key, Member: member, Step: d.step, TTL: d.ttl})
+		}
+	}
+	results, err := b.backend.IncrBatch(ctx, ops)
+	if err == nil && len(results) != len(ops) {
+		// 无法确定哪些增量已写入，重试可能重复计数
+		err = fmt.Errorf("%w: %d results for %d ops", errShortReply, len(results), len(ops))
+	}
+	if len(results) != len(ops) {
+		results = nil
+	}
+
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	b.flushing = nil
+	b.base = make(map[string]map[string]int64, len(batch))
+	var dropped []IncrOp
+	for i, op := range ops {
+		opErr := err
+		if results != nil {
+			opErr = results[i].Err
+		}
+		if opErr == nil {
+			if b.base[op.Key] == nil {
+				b.base[op.Key] = make(map[string]int64)
+			}
+			b.base[op.Key][op.Member] = results[i].Value
+			continue
+		}
+		if err == nil {
+			err = opErr
+		}
+		if b.policy == RetryOnError && retryable(opErr) {
+			b.add(op.Key, op.Member, op.Step, op.TTL)
+			continue
+		}
+		dropped = append(dropped, op)
+	}
+	return dropped, err
+}
+
+func (b *BufferedCounter) incr(ctx context.Context, key, member string, step int64, ttl time.Duration) (int64, error) {
+	b.mux.Lock()
+	if b.closed {
+		b.mux.Unlock()
+		if member == "" {
+			return b.backend.Incr(ctx, key, step, ttl)
+		}
+		return b.backend.IncrWithGroup(ctx, key, member, step, ttl)
+	}
+
+	if _, ok := b.base[key][member]; !ok {
+		b.mux.Unlock()
+		return b.seedIncr(ctx, key, member, step, ttl)
+	}
+
+	b.add(key, member, step, ttl)
+	n := b.base[key][member] + b.unflushed(key, member)
+	full := b.maxPending > 0 && b.count >= b.maxPending
+	b.mux.Unlock()
+
+	if full {
+		b.notifyFlush()
+	}
+	return n, nil
+}
+
+// seedIncr 首次写入时从后端读取当前值作为 base，读取与写入本地增量期间持有 flushMux，
+// 避免 flush 在两者之间重置 base
+func (b *BufferedCounter) seedIncr(ctx context.Context, key, member string, step int64, ttl time.Duration) (int64, error) {
+	b.flushMux.Lock()
+	defer b.flushMux.Unlock()
+
+	var (
+		n   int64
+		err error
+	)
+	if member == "" {
+		n, err = b.backend.Get(ctx, key)
+	} else {
+		n, err = b.backend.GetFromGroup(ctx, key, member)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	b.mux.Lock()
+	if b.closed {
+		b.mux.Unlock()
+		if member == "" {
+			return b.backend.Incr(ctx, key, step, ttl)
+		}
+		return b.backend.IncrWithGroup(ctx, key, member, step, ttl)
+	}
+
+	// flushMux 保证 flushing 为空，后端值不含任何本地增量
+	if b.base == nil {
+		b.base = make(map[string]map[string]int64)
+	}
+	if b.base[key] == nil {
+		b.base[key] = make(map[string]int64)
+	}
+	b.base[key][member] = n
+	b.add(key, member, step, ttl)
+	n += b.unflushed(key, member)
+	full := b.maxPending > 0 && b.count >= b.maxPending
+	b.mux.Unlock()
+
+	if full {
+		b.notifyFlush()
+	}
+	return n, nil
+}
+
+func (b *BufferedCounter) notifyFlush() {
+	select {
+	case b.notify <- struct{}{}:
+	default:
+	}
+}
+
+
+func (b *BufferedCounter) add(key, member string, step int64, ttl time.Duration) {
+	members, ok := b.pending[key]
+	if !ok {
+		members = make(map[string]*delta)
+		b.pending[key] = members
+	}
+	d, ok := members[member]
+	if !ok {
+		d = &delta{}
+		members[member] = d
+		b.count++
+	}
+	d.step += step
+	d.ttl = ttl
+}
+
+func (b *BufferedCounter) unflushed(key, member string) int64 {
+	var n int64
+	if d, ok := b.pending[key][member]; ok {
+		n += d.step
+	}
+	if d, ok := b.flushing[key][member]; ok {
+		n += d.step
+	}
+	return n
+}
+
+var errShortReply = errors.New("counter: short IncrBatch reply")
+
+// retryable 判断失败的增量是否值得重试：redis 的错误回复中只有 LOADING、TRYAGAIN 等表示暂时状态，
+// 其余（如 WRONGTYPE）以及成员数超限、应答数量不符重试也不会成功；网络错误、超时等视为暂时错误
+func retryable(err error) bool {
+	if errors.Is(err, ErrTooManyMembers) || errors.Is(err, errShortReply) {
+		return false
+	}
+
+	var rerr redis.Error
+	if !errors.As(err, &rerr) {
+		return true
+	}
+	for _, prefix := range []string{"LOADING", "TRYAGAIN", "CLUSTERDOWN", "MASTERDOWN", "BUSY"} {
+		if redis.HasErrorPrefix(rerr, prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,326 @@
+package counter
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+type failCounter struct {
+	Counter
+	mux   sync.Mutex
+	fail  bool
+	err   error // 为 nil 时返回暂时错误
+	short bool
+}
+
+func (f *failCounter) IncrBatch(ctx context.Context, ops []IncrOp) ([]IncrResult, error) {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	if f.short {
+		return nil, nil
+	}
+	if f.fail {
+		err := f.err
+		if err == nil {
+			err = errors.New("backend unavailable")
+		}
+		results := make([]IncrResult, len(ops))
+		for i := range results {
+			results[i].Err = err
+		}
+		return results, err
+	}
+	return f.Counter.IncrBatch(ctx, ops)
+}
+
+func TestBufferedCounter_Incr(t *testing.T) {
+	backend := initMemCounter()
+	counter := NewBufferedCounter(backend, time.Hour, 0)
+	defer counter.Close()
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		_, _ = counter.Incr(ctx, "t1", 2, time.Second)
+		_, _ = counter.IncrWithGroup(ctx, "g1", "m1", 1, time.Second)
+	}
+
+	n, _ := backend.Get(ctx, "t1")
+	if n != 0 {
+		t.Errorf("expect 0, but got %d", n)
+	}
+	n, _ = counter.Get(ctx, "t1")
+	if n != 6 {
+		t.Errorf("expect 6, but got %d", n)
+	}
+	n, _ = counter.GetFromGroup(ctx, "g1", "m1")
+	if n != 3 {
+		t.Errorf("expect 3, but got %d", n)
+	}
+
+	if err := counter.Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+	n, _ = backend.Get(ctx, "t1")
+	if n != 6 {
+		t.Errorf("expect 6, but got %d", n)
+	}
+	m, _ := backend.GetAllFromGroup(ctx, "g1")
+	if m["m1"] != 3 {
+		t.Errorf("expect 3, but got %d", m["m1"])
+	}
+
+	n, _ = counter.Incr(ctx, "t1", 1, time.Second)
+	if n != 7 {
+		t.Errorf("expect 7, but got %d", n)
+	}
+	m, _ = counter.MGetFromGroup(ctx, "g1", "m1", "m2")
+	if m["m1"] != 3 || m["m2"] != 0 {
+		t.Errorf("expect m1=3 m2=0, but got %v", m)
+	}
+	n, _ = counter.Get(ctx, "t1")
+	if n != 7 {
+		t.Errorf("expect 7, but got %d", n)
+	}
+}
+
+func TestBufferedCounter_IncrExisting(t *testing.T) {
+	backend := initMemCounter()
+	counter := NewBufferedCounter(backend, time.Hour, 0)
+	defer counter.Close()
+	ctx := context.Background()
+
+	_, _ = backend.Incr(ctx, "t1", 10, time.Second)
+	_, _ = backend.IncrWithGroup(ctx, "g1", "m1", 5, time.Second)
+
+	// key 不在上一次 flush 中时，返回值仍为后端值加上本地增量
+	n, err := counter.Incr(ctx, "t1", 1, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 11 {
+		t.Errorf("expect 11, but got %d", n)
+	}
+	n, _ = counter.IncrWithGroup(ctx, "g1", "m1", 2, time.Second)
+	if n != 7 {
+		t.Errorf("expect 7, but got %d", n)
+	}
+
+	_ = counter.Flush(ctx)
+	_, _ = backend.Incr(ctx, "t2", 3, time.Second)
+	n, _ = counter.Incr(ctx, "t2", 1, time.Second)
+	if n != 4 {
+		t.Errorf("expect 4, but got %d", n)
+	}
+	n, _ = counter.Incr(ctx, "t1", 1, time.Second)
+	if n != 12 {
+		t.Errorf("expect 12, but got %d", n)
+	}
+}
+
+func TestBufferedCounter_MaxPending(t *testing.T) {
+	backend := initMemCounter()
+	counter := NewBufferedCounter(backend, time.Hour, 2)
+	defer counter.Close()
+	ctx := context.Background()
+
+	_, _ = counter.Incr(ctx, "t1", 1, time.Second)
+	_, _ = counter.Incr(ctx, "t2", 1, time.Second)
+
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		if n, _ := backend.Get(ctx, "t2"); n == 1 {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Error("expect flush when pending reaches maxPending")
+}
+
+func TestBufferedCounter_Close(t *testing.T) {
+	backend := initMemCounter()
+	counter := NewBufferedCounter(backend, time.Hour, 0)
+	ctx := context.Background()
+
+	var w sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		w.Add(1)
+		go func() {
+			defer w.Done()
+			_, _ = counter.Incr(ctx, "t1", 1, time.Second)
+		}()
+	}
+	w.Wait()
+
+	if err := counter.Close(); err != nil {
+		t.Fatal(err)
+	}
+	n, _ := backend.Get(ctx, "t1")
+	if n != 100 {
+		t.Errorf("expect 100, but got %d", n)
+	}
+
+	n, _ = counter.Incr(ctx, "t1", 1, time.Second)
+	if n != 101 {
+		t.Errorf("expect 101, but got %d", n)
+	}
+	if err := counter.Close(); err != nil {
+		t.Errorf("expect nil, but got %v", err)
+	}
+}
+
+func TestBufferedCounter_LossPolicy(t *testing.T) {
+	tests := []struct {
+		policy LossPolicy
+		expect int64
+	}{
+		{RetryOnError, 3},
+		{DropOnError, 1},
+	}
+
+	ctx := context.Background()
+	for _, test := range tests {
+		backend := &failCounter{Counter: initMemCounter()}
+		counter := NewBufferedCounter(backend, time.Hour, 0)
+		counter.SetLossPolicy(test.policy)
+
+		_, _ = counter.Incr(ctx, "t1", 2, time.Second)
+		backend.fail = true
+		if err := counter.Flush(ctx); err == nil {
+			t.Error("expect error, but got nil")
+		}
+		backend.fail = false
+
+		_, _ = counter.Incr(ctx, "t1", 1, time.Second)
+		if err := counter.Close(); err != nil {
+			t.Fatal(err)
+		}
+		n, _ := backend.Get(ctx, "t1")
+		if n != test.expect {
+			t.Errorf("expect %d, but got %d", test.expect, n)
+		}
+	}
+
+	backend := &failCounter{Counter: initMemCounter(), fail: true}
+	counter := NewBufferedCounter(backend, time.Hour, 0)
+	_, _ = counter.Incr(ctx, "t1", 1, time.Second)
+	var flushErr *FlushError
+	if err := counter.Close(); !errors.As(err, &flushErr) || len(flushErr.Unflushed) != 1 {
+		t.Fatalf("expect 1 unflushed delta, but got %v", err)
+	}
+	if op := flushErr.Unflushed[0]; op.Key != "t1" || op.Step != 1 {
+		t.Errorf("expect t1 +1, but got %+v", op)
+	}
+}
+
+func TestBufferedCounter_PermanentError(t *testing.T) {
+	ctx := context.Background()
+	backend := &failCounter{Counter: initMemCounter(), fail: true, err: ErrTooManyMembers}
+	counter := NewBufferedCounter(backend, time.Hour, 0)
+
+	_, _ = counter.IncrWithGroup(ctx, "g1", "m1", 2, time.Second)
+	if err := counter.Flush(ctx); !errors.Is(err, ErrTooManyMembers) {
+		t.Errorf("expect %v, but got %v", ErrTooManyMembers, err)
+	}
+	// 永久错误不重试
+	backend.fail = false
+	if err := counter.Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+	n, _ := backend.GetFromGroup(ctx, "g1", "m1")
+	if n != 0 {
+		t.Errorf("expect 0, but got %d", n)
+	}
+	if err := counter.Close(); err != nil {
+		t.Errorf("expect nil, but got %v", err)
+	}
+
+	tests := []struct {
+		err    error
+		expect bool
+	}{
+		{errors.New("dial tcp: connection refused"), true},
+		{context.DeadlineExceeded, true},
+		{ErrTooManyMembers, false},
+		{errShortReply, false},
+		{redis.Nil, false},
+	}
+	for _, test := range tests {
+		if retryable(test.err) != test.expect {
+			t.Errorf("%v: expect %v, but got %v", test.err, test.expect, !test.expect)
+		}
+	}
+}
+
+func TestBufferedCounter_BackgroundRejected(t *testing.T) {
+	ctx := context.Background()
+	backend := &failCounter{Counter: initMemCounter(), fail: true, err: ErrTooManyMembers}
+	counter := NewBufferedCounter(backend, time.Millisecond, 0)
+
+	_, _ = counter.IncrWithGroup(ctx, "g1", "m1", 2, time.Second)
+	deadline := time.Now().Add(time.Second)
+	for counter.pendingLen() > 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+
+	// 后台 flush 丢弃的增量由 Close 返回
+	var flushErr *FlushError
+	if err := counter.Close(); !errors.As(err, &flushErr) || !errors.Is(err, ErrTooManyMembers) {
+		t.Fatalf("expect %v, but got %v", ErrTooManyMembers, err)
+	}
+	if len(flushErr.Unflushed) != 1 || flushErr.Unflushed[0].Member != "m1" {
+		t.Errorf("expect m1 unflushed, but got %+v", flushErr.Unflushed)
+	}
+}
+
+func TestBufferedCounter_ShortReply(t *testing.T) {
+	ctx := context.Background()
+	backend := &failCounter{Counter: initMemCounter(), short: true}
+	counter := NewBufferedCounter(backend, time.Hour, 0)
+
+	_, _ = counter.Incr(ctx, "t1", 1, time.Second)
+	_, _ = counter.Incr(ctx, "t2", 1, time.Second)
+	if err := counter.Flush(ctx); !errors.Is(err, errShortReply) {
+		t.Errorf("expect %v, but got %v", errShortReply, err)
+	}
+	if err := counter.Close(); err != nil {
+		t.Errorf("expect nil, but got %v", err)
+	}
+}
+
+func TestBufferedCounter_CloseContext(t *testing.T) {
+	ctx := context.Background()
+	backend := &failCounter{Counter: initMemCounter(), fail: true}
+	counter := NewBufferedCounter(backend, time.Hour, 0)
+	_, _ = counter.Incr(ctx, "t1", 3, time.Second)
+
+	go func() {
+		time.Sleep(2 * closeRetryInterval)
+		backend.mux.Lock()
+		backend.fail = false
+		backend.mux.Unlock()
+	}()
+	closeCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	if err := counter.CloseContext(closeCtx); err != nil {
+		t.Fatal(err)
+	}
+	n, _ := backend.Get(ctx, "t1")
+	if n != 3 {
+		t.Errorf("expect 3, but got %d", n)
+	}
+
+	backend = &failCounter{Counter: initMemCounter(), fail: true}
+	counter = NewBufferedCounter(backend, time.Hour, 0)
+	_, _ = counter.Incr(ctx, "t1", 3, time.Second)
+	closeCtx, cancel = context.WithTimeout(ctx, 3*closeRetryInterval)
+	defer cancel()
+	var flushErr *FlushError
+	if err := counter.CloseContext(closeCtx); !errors.As(err, &flushErr) || len(flushErr.Unflushed) != 1 {
+		t.Errorf("expect 1 unflushed delta, but got %v", err)
+	}
+}
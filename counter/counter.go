@@ -14,7 +14,33 @@ type Counter interface {
 	GetFromGroup(ctx context.Context, group, member string) (int64, error)
 	MGetFromGroup(ctx context.Context, group string, members ...string) (map[string]int64, error)
 	GetAllFromGroup(ctx context.Context, group string) (map[string]int64, error)
+	// Renew key 不存在时返回 false；ttl 不大于 0 时，内存计数器移除过期时间，redis 计数器与 EXPIRE 一致删除 key
 	Renew(ctx context.Context, keyOrGroup string, ttl time.Duration) (bool, error)
+	// TTL 返回剩余过期时间，key 不存在时返回 0，未设置过期时间时返回 -1
+	TTL(ctx context.Context, keyOrGroup string) (time.Duration, error)
 	Clean(ctx context.Context, keyOrGroup string) error
 	ResetGroup(ctx context.Context, group string, members ...string) error
+	// TopFromGroup 返回计数排名前 k 的成员，desc 为 true 时从大到小；计数相同时按成员名排序，方向与 desc 一致
+	TopFromGroup(ctx context.Context, group string, k int, desc bool) ([]GroupMember, error)
+	// IncrBatch 批量自增，结果与 ops 一一对应，error 为第一个失败操作的错误
+	IncrBatch(ctx context.Context, ops []IncrOp) ([]IncrResult, error)
+	// IncrWindow 滑动窗口计数，窗口按 resolution 划分为子桶，返回最近 window 内的累计值
+	IncrWindow(ctx context.Context, key string, step int64, window, resolution time.Duration) (int64, error)
+	// GetWindow 获取最近 window 内的累计值，window 与 resolution 需与 IncrWindow 保持一致
+	GetWindow(ctx context.Context, key string, window, resolution time.Duration) (int64, error)
+}
+
+// IncrOp 批量自增操作，Member 为空时等同于 Incr(Key)，否则等同于 IncrWithGroup(Key, Member)
+type IncrOp struct {
+	Key    string
+	Member string
+	Step   int64
+	// TTL 负数时，不设置过期时间
+	TTL time.Duration
+}
+
+// IncrResult 批量自增中单个操作的结果
+type IncrResult struct {
+	Value int64
+	Err   error
 }
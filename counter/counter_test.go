@@ -0,0 +1,245 @@
+package counter
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCounter_IncrBatch(t *testing.T) {
+	counters := []struct {
+		name    string
+		counter Counter
+	}{
+		{"mem", initMemCounter()},
+		{"redis", initRedisCounter()},
+	}
+
+	ops := []IncrOp{
+		{Key: "b1", Step: 1, TTL: time.Second},
+		{Key: "b2", Step: 2},
+		{Key: "b1", Step: 3, TTL: time.Second},
+		{Key: "bg", Member: "m1", Step: 1, TTL: time.Second},
+		{Key: "bg", Member: "m2", Step: 5, TTL: -1},
+		{Key: "bg", Member: "m1", Step: -2, TTL: time.Second},
+	}
+	expects := []int64{1, 2, 4, 1, 5, -1}
+
+	for _, c := range counters {
+		cc := c
+		t.Run(c.name, func(t *testing.T) {
+			ctx := context.Background()
+			for _, key := range []string{"b1", "b2", "bg"} {
+				_ = cc.counter.Clean(ctx, key)
+			}
+
+			results, err := cc.counter.IncrBatch(ctx, ops)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(results) != len(ops) {
+				t.Fatalf("expect %d, but got %d", len(ops), len(results))
+			}
+			for i, res := range results {
+				if res.Err != nil {
+					t.Errorf("op %d: %v", i, res.Err)
+				}
+				if res.Value != expects[i] {
+					t.Errorf("op %d: expect %d, but got %d", i, expects[i], res.Value)
+				}
+			}
+
+			n, _ := cc.counter.Get(ctx, "b1")
+			if n != 4 {
+				t.Errorf("expect 4, but got %d", n)
+			}
+			m, _ := cc.counter.GetAllFromGroup(ctx, "bg")
+			if m["m1"] != -1 || m["m2"] != 5 {
+				t.Errorf("expect m1=-1 m2=5, but got %v", m)
+			}
+
+			results, err = cc.counter.IncrBatch(ctx, nil)
+			if err != nil || len(results) != 0 {
+				t.Errorf("expect empty result, but got %v %v", results, err)
+			}
+
+			for _, key := range []string{"b1", "b2", "bg"} {
+				_ = cc.counter.Clean(ctx, key)
+			}
+		})
+	}
+}
+
+func TestCounter_TTL(t *testing.T) {
+	// Renew ttl 不大于 0 时，mem 移除过期时间，redis 与 EXPIRE 一致删除 key
+	counters := []struct {
+		name    string
+		counter Counter
+		persist bool
+	}{
+		{"mem", initMemCounter(), true},
+		{"redis", initRedisCounter(), false},
+	}
+
+	tests := []struct {
+		key    string
+		member string
+		ttl    time.Duration
+		min    time.Duration
+		max    time.Duration
+	}{
+		{"ttl1", "", 1500 * time.Millisecond, 1400 * time.Millisecond, 1500 * time.Millisecond},
+		{"ttl2", "", 500 * time.Millisecond, 400 * time.Millisecond, 500 * time.Millisecond},
+		{"ttl3", "", time.Microsecond, 0, time.Millisecond},
+		{"ttl4", "", 0, -1, -1},
+		{"ttl5", "", -1, -1, -1},
+		{"ttl6", "m1", 500 * time.Millisecond, 400 * time.Millisecond, 500 * time.Millisecond},
+		{"ttl7", "m1", -1, -1, -1},
+	}
+
+	for _, c := range counters {
+		cc := c
+		t.Run(c.name, func(t *testing.T) {
+			ctx := context.Background()
+			for _, test := range tests {
+				_ = cc.counter.Clean(ctx, test.key)
+				var err error
+				if test.member == "" {
+					_, err = cc.counter.Incr(ctx, test.key, 1, test.ttl)
+				} else {
+					_, err = cc.counter.IncrWithGroup(ctx, test.key, test.member, 1, test.ttl)
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				d, err := cc.counter.TTL(ctx, test.key)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if d < test.min || d > test.max {
+					t.Errorf("%s: expect ttl in [%s, %s], but got %s", test.key, test.min, test.max, d)
+				}
+			}
+
+			d, _ := cc.counter.TTL(ctx, "ttlMissing")
+			if d != 0 {
+				t.Errorf("expect 0, but got %s", d)
+			}
+			ok, _ := cc.counter.Renew(ctx, "ttlMissing", time.Second)
+			if ok {
+				t.Errorf("expect false, but got true")
+			}
+
+			ok, _ = cc.counter.Renew(ctx, "ttl1", 200*time.Millisecond)
+			d, _ = cc.counter.TTL(ctx, "ttl1")
+			if !ok || d <= 100*time.Millisecond || d > 200*time.Millisecond {
+				t.Errorf("expect ttl in (100ms, 200ms], but got %s", d)
+			}
+			ok, _ = cc.counter.Renew(ctx, "ttl1", 0)
+			d, _ = cc.counter.TTL(ctx, "ttl1")
+			n, _ := cc.counter.Get(ctx, "ttl1")
+			if cc.persist && (!ok || d != -1 || n == 0) {
+				t.Errorf("expect persisted, but got %t %s %d", ok, d, n)
+			}
+			if !cc.persist && (!ok || d != 0 || n != 0) {
+				t.Errorf("expect deleted, but got %t %s %d", ok, d, n)
+			}
+
+			_, _ = cc.counter.Incr(ctx, "ttl8", 1, 5*time.Millisecond)
+			time.Sleep(10 * time.Millisecond)
+			n, _ = cc.counter.Get(ctx, "ttl8")
+			d, _ = cc.counter.TTL(ctx, "ttl8")
+			if n != 0 || d != 0 {
+				t.Errorf("expect expired, but got %d %s", n, d)
+			}
+
+			for _, test := range tests {
+				_ = cc.counter.Clean(ctx, test.key)
+			}
+		})
+	}
+}
+
+func TestCounter_TopFromGroup(t *testing.T) {
+	counters := []struct {
+		name    string
+		counter Counter
+	}{
+		{"mem", initMemCounter()},
+		{"redis", initRedisCounter()},
+		{"redisTop", initRedisTopCounter()},
+	}
+
+	members := []struct {
+		member string
+		step   int64
+	}{
+		{"a", 3}, {"b", 1}, {"c", 5}, {"d", 3}, {"e", -2},
+	}
+	tests := []struct {
+		k      int
+		desc   bool
+		expect []GroupMember
+	}{
+		{3, true, []GroupMember{{"c", 5}, {"d", 3}, {"a", 3}}},
+		{3, false, []GroupMember{{"e", -2}, {"b", 1}, {"a", 3}}},
+		{1, true, []GroupMember{{"c", 5}}},
+		{10, false, []GroupMember{{"e", -2}, {"b", 1}, {"a", 3}, {"d", 3}, {"c", 5}}},
+		{0, true, []GroupMember{}},
+	}
+
+	for _, c := range counters {
+		cc := c
+		t.Run(c.name, func(t *testing.T) {
+			ctx := context.Background()
+			group := "testTop"
+			_ = cc.counter.Clean(ctx, group)
+			for _, m := range members {
+				if _, err := cc.counter.IncrWithGroup(ctx, group, m.member, m.step, time.Second); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			for _, test := range tests {
+				top, err := cc.counter.TopFromGroup(ctx, group, test.k, test.desc)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !reflect.DeepEqual(top, test.expect) {
+					t.Errorf("k=%d desc=%v: expect %v, but got %v", test.k, test.desc, test.expect, top)
+				}
+			}
+
+			_ = cc.counter.ResetGroup(ctx, group, "c")
+			top, _ := cc.counter.TopFromGroup(ctx, group, 1, true)
+			if len(top) != 1 || top[0].Member != "d" {
+				t.Errorf("expect d, but got %v", top)
+			}
+
+			_ = cc.counter.Clean(ctx, group)
+			top, _ = cc.counter.TopFromGroup(ctx, group, 3, true)
+			if len(top) != 0 {
+				t.Errorf("expect empty, but got %v", top)
+			}
+		})
+	}
+}
+
+func TestTopKey(t *testing.T) {
+	tests := []struct {
+		group  string
+		expect string
+	}{
+		{"g1", "{g1}:top"},
+		{"{user}.g1", "{user}.g1:top"},
+		{"a{b", "{a{b}:top"},
+		{"{}g1", ""},
+		{"a}b", ""},
+	}
+	for _, test := range tests {
+		if z := topKey(test.group); z != test.expect {
+			t.Errorf("expect %q, but got %q", test.expect, z)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package counter
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+// 与 redis 的 HyperLogLog 保持一致：2^14 个 6 bit 寄存器，MurmurHash64A，标准误差约 0.81%
+const (
+	hllP        = 14
+	hllQ        = 64 - hllP
+	hllRegs     = 1 << hllP
+	hllSeed     = 0xadc83b19
+	hllAlphaInf = 0.721347520444481703680
+)
+
+type hyperLogLog struct {
+	regs [hllRegs]uint8
+}
+
+// add 返回寄存器是否发生变化
+func (h *hyperLogLog) add(member string) bool {
+	hash := murmurHash64A([]byte(member), hllSeed)
+	index := hash & (hllRegs - 1)
+	hash >>= hllP
+	hash |= 1 << hllQ
+
+	count := uint8(1)
+	for bit := uint64(1); hash&bit == 0; bit <<= 1 {
+		count++
+	}
+
+	if h.regs[index] < count {
+		h.regs[index] = count
+		return true
+	}
+	return false
+}
+
+func (h *hyperLogLog) merge(o *hyperLogLog) {
+	for i, v := range o.regs {
+		if h.regs[i] < v {
+			h.regs[i] = v
+		}
+	}
+}
+
+// count 使用 Otmar Ertl 的估算方法，与 redis hllCount 相同
+func (h *hyperLogLog) count() int64 {
+	var histo [hllQ + 2]int
+	for _, v := range h.regs {
+		histo[v]++
+	}
+
+	m := float64(hllRegs)
+	z := m * hllTau((m-float64(histo[hllQ+1]))/m)
+	for j := hllQ; j >= 1; j-- {
+		z += float64(histo[j])
+		z *= 0.5
+	}
+	z += m * hllSigma(float64(histo[0])/m)
+	return int64(math.Round(hllAlphaInf * m * m / z))
+}
+
+func hllSigma(x float64) float64 {
+	if x == 1 {
+		return math.Inf(1)
+	}
+	y, z := 1.0, x
+	for {
+		x *= x
+		zPrime := z
+		z += x * y
+		y += y
+		if zPrime == z {
+			return z
+		}
+	}
+}
+
+func hllTau(x float64) float64 {
+	if x == 0 || x == 1 {
+		return 0
+	}
+	y, z := 1.0, 1-x
+	for {
+		x = math.Sqrt(x)
+		zPrime := z
+		y *= 0.5
+		z -= (1 - x) * (1 - x) * y
+		if zPrime == z {
+			return z / 3
+		}
+	}
+}
+
+func murmurHash64A(data []byte, seed uint64) uint64 {
+	const (
+		m = 0xc6a4a7935bd1e995
+		r = 47
+	)
+
+	h := seed ^ (uint64(len(data)) * m)
+	for len(data) >= 8 {
+		k := binary.LittleEndian.Uint64(data)
+		k *= m
+		k ^= k >> r
+		k *= m
+
+		h ^= k
+		h *= m
+		data = data[8:]
+	}
+
+	if len(data) > 0 {
+		for i := len(data) - 1; i >= 0; i-- {
+			h ^= uint64(data[i]) << (8 * uint(i))
+		}
+		h *= m
+	}
+
+	h ^= h >> r
+	h *= m
+	h ^= h >> r
+	return h
+}
@@ -0,0 +1,71 @@
+package counter
+
+import (
+	"math"
+	"strconv"
+	"testing"
+)
+
+func TestHyperLogLog_Count(t *testing.T) {
+	tests := []struct {
+		n       int
+		epsilon float64
+	}{
+		{0, 0},
+		{1, 0},
+		{10, 0},
+		{1000, 0.01},
+		{100000, 0.02},
+		{1000000, 0.02},
+	}
+
+	for _, test := range tests {
+		var h hyperLogLog
+		for i := 0; i < test.n; i++ {
+			h.add(strconv.Itoa(i))
+		}
+		// 重复添加不影响计数
+		for i := 0; i < test.n; i += 2 {
+			if h.add(strconv.Itoa(i)) {
+				t.Fatalf("n=%d: re-adding %d changed registers", test.n, i)
+			}
+		}
+		n := h.count()
+		if math.Abs(float64(n)-float64(test.n)) > float64(test.n)*test.epsilon {
+			t.Errorf("n=%d: expect error within %.2f, but got %d", test.n, test.epsilon, n)
+		}
+	}
+}
+
+func TestHyperLogLog_Merge(t *testing.T) {
+	var a, b hyperLogLog
+	for i := 0; i < 5000; i++ {
+		a.add(strconv.Itoa(i))
+	}
+	for i := 2500; i < 7500; i++ {
+		b.add(strconv.Itoa(i))
+	}
+	a.merge(&b)
+
+	n := a.count()
+	if math.Abs(float64(n)-7500) > 7500*0.02 {
+		t.Errorf("expect about 7500, but got %d", n)
+	}
+}
+
+func TestMurmurHash64A(t *testing.T) {
+	tests := []struct {
+		data   string
+		expect uint64
+	}{
+		{"", 0xd8dfea6585bc9732},
+		{"a", 0x53d2470a9b43b1a7},
+		{"hello", 0x0f656f01eecfe400},
+		{"hello world!", 0x0fc444011f57220c},
+	}
+	for _, test := range tests {
+		if h := murmurHash64A([]byte(test.data), hllSeed); h != test.expect {
+			t.Errorf("%q: expect %#x, but got %#x", test.data, test.expect, h)
+		}
+	}
+}
@@ -2,41 +2,110 @@ package counter
 
 import (
 	"context"
+	"errors"
+	"io"
+	"math"
 	"sync"
 	"time"
 )
 
+const (
+	// evictSamples 淘汰时随机采样的 entry 数
+	evictSamples = 5
+	// defaultShards 默认分片数
+	defaultShards = 32
+)
+
+var ErrTooManyMembers = errors.New("counter: too many members in group")
+
+// MemCounter 内存计数器，Close 停止后台过期清理与定期快照
+type MemCounter interface {
+	Counter
+	Snapshot(w io.Writer) error
+	Restore(r io.Reader) error
+	SnapshotToFile(path string, interval time.Duration) error
+	Close() error
+}
+
 type memCounter struct {
-	entries map[string]*entry
-	mux     sync.RWMutex
+	shards    []*counterShard
+	done      chan struct{}
+	closeOnce sync.Once
+
+	snapshotMux  sync.Mutex
+	snapshotPath string
+	snapshotWg   sync.WaitGroup
+}
+
+// counterShard 按 key 哈希分片，各分片独立加锁与清理过期 entry
+type counterShard struct {
+	entries    map[string]*entry
+	mux        sync.RWMutex
+	maxEntries int
+	maxMembers int
 }
 
 type entry struct {
 	value   int64
 	members map[string]int64
+	window  *slidingWindow
 	expire  int64
 }
 
-func NewMemCounter(cap int, checkExpInterval time.Duration) Counter {
+func NewMemCounter(cap int, checkExpInterval time.Duration) MemCounter {
+	return NewMemCounterWithLimit(cap, checkExpInterval, 0, 0)
+}
+
+// NewMemCounterWithLimit maxEntries 限制 key/group 总数，达到上限时随机采样淘汰已过期或最早过期的 entry；
+// maxMembers 限制单个 group 的成员数，达到上限时新成员自增返回 ErrTooManyMembers。不大于 0 时不限制
+func NewMemCounterWithLimit(cap int, checkExpInterval time.Duration, maxEntries, maxMembers int) MemCounter {
+	return NewShardedMemCounter(defaultShards, cap, checkExpInterval, maxEntries, maxMembers)
+}
+
+// NewShardedMemCounter shards 为分片数，不大于 0 时使用默认值；cap 为总容量。
+// maxEntries 平均分配到各分片，分片数大于 maxEntries 时减少分片数，
+// 因此单个分片可能先于总数达到上限而触发淘汰
+func NewShardedMemCounter(shards, cap int, checkExpInterval time.Duration, maxEntries, maxMembers int) MemCounter {
+	if shards <= 0 {
+		shards = defaultShards
+	}
+	if maxEntries > 0 && shards > maxEntries {
+		shards = maxEntries
+	}
+
 	c := &memCounter{
-		entries: make(map[string]*entry, cap),
+		shards: make([]*counterShard, shards),
+		done:   make(chan struct{}),
+	}
+	for i := range c.shards {
+		c.shards[i] = &counterShard{
+			entries:    make(map[string]*entry, cap/shards),
+			maxEntries: maxEntries / shards,
+			maxMembers: maxMembers,
+		}
 	}
 
 	if checkExpInterval > 0 {
 		go func() {
 			ticker := time.NewTicker(checkExpInterval)
+			defer ticker.Stop()
 
 			for {
 				select {
 				case now := <-ticker.C:
+					// 逐个分片清理，避免长时间阻塞全部读写
 					timestamp := now.UnixNano()
-					c.mux.Lock()
-					for k, v := range c.entries {
-						if v.expire < timestamp {
-							delete(c.entries, k)
+					for _, s := range c.shards {
+						s.mux.Lock()
+						for k, v := range s.entries {
+							if v.expire > 0 && v.expire < timestamp {
+								delete(s.entries, k)
+							}
 						}
+						s.mux.Unlock()
 					}
-					c.mux.Unlock()
+				case <-c.done:
+					return
 				}
 			}
 		}()
@@ -45,35 +114,42 @@ func NewMemCounter(cap int, checkExpInterval time.Duration) Counter {
 }
 
 func (m *memCounter) Incr(ctx context.Context, key string, step int64, ttl time.Duration) (int64, error) {
+	s := m.shard(key)
 	now := time.Now()
 
-	m.mux.Lock()
-	defer m.mux.Unlock()
+	s.mux.Lock()
+	defer s.mux.Unlock()
 
-	v, ok := m.entries[key]
+	return s.incr(now, key, step, ttl), nil
+}
+
+func (s *counterShard) incr(now time.Time, key string, step int64, ttl time.Duration) int64 {
+	v, ok := s.entries[key]
 	if ok {
 		if v.IsExpired(now) {
 			v.expire = calcExpire(now, ttl)
 			v.value = 0
 		}
 		v.value += step
-		return v.value, nil
+		return v.value
 	}
 
-	m.entries[key] = &entry{
+	s.evict(now)
+	s.entries[key] = &entry{
 		value:   step,
 		members: make(map[string]int64),
 		expire:  calcExpire(now, ttl),
 	}
 
-	return step, nil
+	return step
 }
 
 func (m *memCounter) Get(ctx context.Context, key string) (int64, error) {
-	m.mux.RLock()
-	defer m.mux.RUnlock()
+	s := m.shard(key)
+	s.mux.RLock()
+	defer s.mux.RUnlock()
 
-	v, ok := m.entries[key]
+	v, ok := s.entries[key]
 	if !ok {
 		return 0, nil
 	}
@@ -85,23 +161,35 @@ func (m *memCounter) Get(ctx context.Context, key string) (int64, error) {
 }
 
 func (m *memCounter) IncrWithGroup(ctx context.Context, group, member string, step int64, ttl time.Duration) (int64, error) {
+	s := m.shard(group)
 	now := time.Now()
 
-	m.mux.Lock()
-	defer m.mux.Unlock()
+	s.mux.Lock()
+	defer s.mux.Unlock()
 
-	v, ok := m.entries[group]
+	return s.incrWithGroup(now, group, member, step, ttl)
+}
+
+func (s *counterShard) incrWithGroup(now time.Time, group, member string, step int64, ttl time.Duration) (int64, error) {
+	v, ok := s.entries[group]
 	if ok {
 		if v.IsExpired(now) {
 			v.expire = calcExpire(now, ttl)
 			v.value = 0
 			v.members = make(map[string]int64, len(v.members))
 		}
+		if v.members == nil {
+			v.members = make(map[string]int64)
+		}
+		if _, ok := v.members[member]; !ok && s.maxMembers > 0 && len(v.members) >= s.maxMembers {
+			return 0, ErrTooManyMembers
+		}
 		v.members[member] += step
 		return v.members[member], nil
 	}
 
-	m.entries[group] = &entry{
+	s.evict(now)
+	s.entries[group] = &entry{
 		members: map[string]int64{
 			member: step,
 		},
@@ -111,11 +199,56 @@ func (m *memCounter) IncrWithGroup(ctx context.Context, group, member string, st
 	return step, nil
 }
 
+func (m *memCounter) TopFromGroup(ctx context.Context, group string, k int, desc bool) ([]GroupMember, error) {
+	s := m.shard(group)
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	v, ok := s.entries[group]
+	if !ok || v.IsExpired(time.Now()) {
+		return []GroupMember{}, nil
+	}
+	return topMembers(v.members, k, desc), nil
+}
+
+// IncrBatch 按分片分组，每个分片只加锁一次
+func (m *memCounter) IncrBatch(ctx context.Context, ops []IncrOp) ([]IncrResult, error) {
+	results := make([]IncrResult, len(ops))
+	now := time.Now()
+
+	groups := make(map[*counterShard][]int)
+	for i, op := range ops {
+		s := m.shard(op.Key)
+		groups[s] = append(groups[s], i)
+	}
+
+	for s, idx := range groups {
+		s.mux.Lock()
+		for _, i := range idx {
+			op := ops[i]
+			if op.Member == "" {
+				results[i].Value = s.incr(now, op.Key, op.Step, op.TTL)
+			} else {
+				results[i].Value, results[i].Err = s.incrWithGroup(now, op.Key, op.Member, op.Step, op.TTL)
+			}
+		}
+		s.mux.Unlock()
+	}
+
+	for _, res := range results {
+		if res.Err != nil {
+			return results, res.Err
+		}
+	}
+	return results, nil
+}
+
 func (m *memCounter) GetFromGroup(ctx context.Context, group, member string) (int64, error) {
-	m.mux.RLock()
-	defer m.mux.RUnlock()
+	s := m.shard(group)
+	s.mux.RLock()
+	defer s.mux.RUnlock()
 
-	v, ok := m.entries[group]
+	v, ok := s.entries[group]
 	if !ok {
 		return 0, nil
 	}
@@ -128,15 +261,16 @@ func (m *memCounter) GetFromGroup(ctx context.Context, group, member string) (in
 }
 
 func (m *memCounter) MGetFromGroup(ctx context.Context, group string, members ...string) (map[string]int64, error) {
+	s := m.shard(group)
 	result := make(map[string]int64, len(members))
 	for _, member := range members {
 		result[member] = 0
 	}
 
-	m.mux.RLock()
-	defer m.mux.RUnlock()
+	s.mux.RLock()
+	defer s.mux.RUnlock()
 
-	v, ok := m.entries[group]
+	v, ok := s.entries[group]
 	if !ok {
 		return result, nil
 	}
@@ -152,10 +286,11 @@ func (m *memCounter) MGetFromGroup(ctx context.Context, group string, members ..
 }
 
 func (m *memCounter) GetAllFromGroup(ctx context.Context, group string) (map[string]int64, error) {
-	m.mux.RLock()
-	defer m.mux.RUnlock()
+	s := m.shard(group)
+	s.mux.RLock()
+	defer s.mux.RUnlock()
 
-	v, ok := m.entries[group]
+	v, ok := s.entries[group]
 	if !ok {
 		return map[string]int64{}, nil
 	}
@@ -172,42 +307,65 @@ func (m *memCounter) GetAllFromGroup(ctx context.Context, group string) (map[str
 }
 
 func (m *memCounter) Renew(ctx context.Context, keyOrGroup string, ttl time.Duration) (bool, error) {
-	m.mux.Lock()
-	defer m.mux.Unlock()
+	s := m.shard(keyOrGroup)
+	s.mux.Lock()
+	defer s.mux.Unlock()
 
-	v, ok := m.entries[keyOrGroup]
+	v, ok := s.entries[keyOrGroup]
 	if !ok {
 		return false, nil
 	}
 
 	now := time.Now()
 	if v.IsExpired(now) {
-		delete(m.entries, keyOrGroup)
+		delete(s.entries, keyOrGroup)
 		return false, nil
 	}
 	v.expire = calcExpire(now, ttl)
 	return true, nil
 }
 
+func (m *memCounter) TTL(ctx context.Context, keyOrGroup string) (time.Duration, error) {
+	s := m.shard(keyOrGroup)
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	v, ok := s.entries[keyOrGroup]
+	if !ok {
+		return 0, nil
+	}
+
+	now := time.Now()
+	if v.IsExpired(now) {
+		return 0, nil
+	}
+	if v.expire == 0 {
+		return -1, nil
+	}
+	return time.Duration(v.expire - now.UnixNano()), nil
+}
+
 func (m *memCounter) Clean(ctx context.Context, keyOrGroup string) error {
-	m.mux.Lock()
-	defer m.mux.Unlock()
+	s := m.shard(keyOrGroup)
+	s.mux.Lock()
+	defer s.mux.Unlock()
 
-	delete(m.entries, keyOrGroup)
+	delete(s.entries, keyOrGroup)
 	return nil
 }
 
 func (m *memCounter) ResetGroup(ctx context.Context, group string, members ...string) error {
-	m.mux.Lock()
-	defer m.mux.Unlock()
+	s := m.shard(group)
+	s.mux.Lock()
+	defer s.mux.Unlock()
 
-	v, ok := m.entries[group]
+	v, ok := s.entries[group]
 	if !ok {
 		return nil
 	}
 
 	if v.IsExpired(time.Now()) {
-		delete(m.entries, group)
+		delete(s.entries, group)
 		return nil
 	}
 
@@ -217,6 +375,128 @@ func (m *memCounter) ResetGroup(ctx context.Context, group string, members ...st
 	return nil
 }
 
+func (m *memCounter) IncrWindow(ctx context.Context, key string, step int64, window, resolution time.Duration) (int64, error) {
+	return m.incrWindow(time.Now(), key, step, window, resolution)
+}
+
+func (m *memCounter) GetWindow(ctx context.Context, key string, window, resolution time.Duration) (int64, error) {
+	return m.getWindow(time.Now(), key, window, resolution)
+}
+
+func (m *memCounter) incrWindow(now time.Time, key string, step int64, window, resolution time.Duration) (int64, error) {
+	s := m.shard(key)
+	cur, n, err := windowBuckets(now, window, resolution)
+	if err != nil {
+		return 0, err
+	}
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	v, ok := s.entries[key]
+	if !ok {
+		s.evict(now)
+		v = &entry{members: make(map[string]int64)}
+		s.entries[key] = v
+	}
+	if v.window == nil || v.IsExpired(now) || !v.window.match(n, resolution) {
+		v.window = newSlidingWindow(n, resolution)
+	}
+	v.window.incr(cur, step)
+	v.expire = calcExpire(now, time.Duration(n)*resolution)
+	return v.window.sum(cur), nil
+}
+
+func (m *memCounter) getWindow(now time.Time, key string, window, resolution time.Duration) (int64, error) {
+	s := m.shard(key)
+	cur, n, err := windowBuckets(now, window, resolution)
+	if err != nil {
+		return 0, err
+	}
+
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	v, ok := s.entries[key]
+	if !ok || v.window == nil || v.IsExpired(now) || !v.window.match(n, resolution) {
+		return 0, nil
+	}
+	return v.window.sum(cur), nil
+}
+
+// Close 停止后台过期清理，开启了 SnapshotToFile 时写入最后一次快照，可重复调用
+func (m *memCounter) Close() error {
+	var err error
+	m.closeOnce.Do(func() {
+		close(m.done)
+		m.snapshotWg.Wait()
+
+		m.snapshotMux.Lock()
+		path := m.snapshotPath
+		m.snapshotMux.Unlock()
+		if path != "" {
+			err = m.snapshotFile(path)
+		}
+	})
+	return err
+}
+
+// evict 在 entry 数达到上限时随机采样，淘汰已过期或最早过期的一个
+func (s *counterShard) evict(now time.Time) {
+	if s.maxEntries <= 0 || len(s.entries) < s.maxEntries {
+		return
+	}
+
+	var (
+		victim string
+		exp    int64
+		n      int
+	)
+	for k, v := range s.entries {
+		if v.IsExpired(now) {
+			delete(s.entries, k)
+			return
+		}
+		// 不过期的 entry 视为最晚过期
+		e := v.expire
+		if e == 0 {
+			e = math.MaxInt64
+		}
+		if n == 0 || e < exp {
+			victim, exp = k, e
+		}
+		n++
+		if n >= evictSamples {
+			break
+		}
+	}
+	delete(s.entries, victim)
+}
+
+func (m *memCounter) shard(key string) *counterShard {
+	if len(m.shards) == 1 {
+		return m.shards[0]
+	}
+	// FNV-1a
+	h := uint32(2166136261)
+	for i := 0; i < len(key); i++ {
+		h ^= uint32(key[i])
+		h *= 16777619
+	}
+	return m.shards[h%uint32(len(m.shards))]
+}
+
+// len 返回 entry 总数，包含尚未清理的过期 entry
+func (m *memCounter) len() int {
+	var n int
+	for _, s := range m.shards {
+		s.mux.RLock()
+		n += len(s.entries)
+		s.mux.RUnlock()
+	}
+	return n
+}
+
 func (e *entry) IsExpired(now time.Time) bool {
 	if e.expire == 0 || e.expire > now.UnixNano() {
 		return false
@@ -2,6 +2,8 @@ package counter
 
 import (
 	"context"
+	"fmt"
+	"runtime"
 	"sync"
 	"testing"
 	"time"
@@ -191,3 +193,180 @@ func TestMemCounter_Incr3(t *testing.T) {
 		t.Errorf("expect 0, but got %d", n)
 	}
 }
+
+func TestMemCounter_Close(t *testing.T) {
+	before := runtime.NumGoroutine()
+	counter := NewMemCounter(10, time.Millisecond)
+	if err := counter.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := counter.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > before && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if n := runtime.NumGoroutine(); n > before {
+		t.Errorf("expect %d goroutines, but got %d", before, n)
+	}
+}
+
+func TestMemCounter_MaxEntries(t *testing.T) {
+	counter := NewShardedMemCounter(1, 10, 0, 3, 0)
+	defer counter.Close()
+	ctx := context.Background()
+
+	_, _ = counter.Incr(ctx, "t1", 1, time.Millisecond)
+	_, _ = counter.Incr(ctx, "t2", 1, -1)
+	_, _ = counter.IncrWithGroup(ctx, "g1", "m1", 1, -1)
+	time.Sleep(2 * time.Millisecond)
+
+	// t1 已过期，优先淘汰
+	_, _ = counter.Incr(ctx, "t3", 1, -1)
+	mc := counter.(*memCounter)
+	if mc.len() != 3 {
+		t.Errorf("expect 3, but got %d", mc.len())
+	}
+	if _, ok := mc.shards[0].entries["t1"]; ok {
+		t.Error("expect t1 evicted")
+	}
+
+	for i := 0; i < 100; i++ {
+		_, _ = counter.Incr(ctx, fmt.Sprintf("k%d", i), 1, time.Duration(i+1)*time.Second)
+		if mc.len() > 3 {
+			t.Fatalf("expect at most 3, but got %d", mc.len())
+		}
+	}
+	n, _ := counter.Get(ctx, "k99")
+	if n != 1 {
+		t.Errorf("expect 1, but got %d", n)
+	}
+}
+
+func TestMemCounter_MaxMembers(t *testing.T) {
+	counter := NewMemCounterWithLimit(10, 0, 0, 2)
+	defer counter.Close()
+	ctx := context.Background()
+
+	_, _ = counter.IncrWithGroup(ctx, "g1", "m1", 1, time.Second)
+	_, _ = counter.IncrWithGroup(ctx, "g1", "m2", 1, time.Second)
+	_, err := counter.IncrWithGroup(ctx, "g1", "m3", 1, time.Second)
+	if err != ErrTooManyMembers {
+		t.Errorf("expect %v, but got %v", ErrTooManyMembers, err)
+	}
+	n, err := counter.IncrWithGroup(ctx, "g1", "m1", 1, time.Second)
+	if err != nil || n != 2 {
+		t.Errorf("expect 2, but got %d %v", n, err)
+	}
+
+	results, err := counter.IncrBatch(ctx, []IncrOp{
+		{Key: "g1", Member: "m2", Step: 1, TTL: time.Second},
+		{Key: "g1", Member: "m4", Step: 1, TTL: time.Second},
+	})
+	if err != ErrTooManyMembers {
+		t.Errorf("expect %v, but got %v", ErrTooManyMembers, err)
+	}
+	if results[0].Value != 2 || results[0].Err != nil || results[1].Err != ErrTooManyMembers {
+		t.Errorf("unexpected results %v", results)
+	}
+}
+
+func TestMemCounter_ShardedMaxEntries(t *testing.T) {
+	counter := NewMemCounterWithLimit(10, 0, 100, 0)
+	defer counter.Close()
+	ctx := context.Background()
+
+	mc := counter.(*memCounter)
+	for i := 0; i < 1000; i++ {
+		_, _ = counter.Incr(ctx, fmt.Sprintf("k%d", i), 1, time.Minute)
+		if mc.len() > 100 {
+			t.Fatalf("expect at most 100, but got %d", mc.len())
+		}
+	}
+
+	counter = NewShardedMemCounter(8, 10, 0, 3, 0)
+	defer counter.Close()
+	if n := len(counter.(*memCounter).shards); n != 3 {
+		t.Errorf("expect 3 shards, but got %d", n)
+	}
+}
+
+func TestMemCounter_ShardedExpire(t *testing.T) {
+	counter := NewShardedMemCounter(4, 10, time.Millisecond, 0, 0)
+	defer counter.Close()
+	ctx := context.Background()
+
+	for i := 0; i < 100; i++ {
+		_, _ = counter.Incr(ctx, fmt.Sprintf("k%d", i), 1, time.Millisecond)
+	}
+	_, _ = counter.Incr(ctx, "keep", 1, -1)
+
+	mc := counter.(*memCounter)
+	deadline := time.Now().Add(time.Second)
+	for mc.len() > 1 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if n := mc.len(); n != 1 {
+		t.Errorf("expect 1, but got %d", n)
+	}
+	n, _ := counter.Get(ctx, "keep")
+	if n != 1 {
+		t.Errorf("expect 1, but got %d", n)
+	}
+}
+
+// 分片数为 1 时等同于原先单锁的实现
+func BenchmarkMemCounter_Incr(b *testing.B) {
+	keys := make([]string, 1024)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("key%d", i)
+	}
+
+	for _, shards := range []int{1, defaultShards} {
+		b.Run(fmt.Sprintf("shards=%d", shards), func(b *testing.B) {
+			counter := NewShardedMemCounter(shards, len(keys), time.Second, 0, 0)
+			defer counter.Close()
+			ctx := context.Background()
+
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				var i int
+				for pb.Next() {
+					_, _ = counter.Incr(ctx, keys[i&1023], 1, time.Minute)
+					i++
+				}
+			})
+		})
+	}
+}
+
+func BenchmarkMemCounter_IncrGet(b *testing.B) {
+	keys := make([]string, 1024)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("key%d", i)
+	}
+
+	for _, shards := range []int{1, defaultShards} {
+		b.Run(fmt.Sprintf("shards=%d", shards), func(b *testing.B) {
+			counter := NewShardedMemCounter(shards, len(keys), time.Second, 0, 0)
+			defer counter.Close()
+			ctx := context.Background()
+
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				var i int
+				for pb.Next() {
+					key := keys[i&1023]
+					if i%4 == 0 {
+						_, _ = counter.Incr(ctx, key, 1, time.Minute)
+					} else {
+						_, _ = counter.Get(ctx, key)
+					}
+					i++
+				}
+			})
+		})
+	}
+}
@@ -0,0 +1,138 @@
+package counter
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemUniqueCounter 内存去重计数器，每个 key 占用约 16KB，Close 停止后台过期清理
+type MemUniqueCounter interface {
+	UniqueCounter
+	Close() error
+}
+
+type memUniqueCounter struct {
+	entries   map[string]*hllEntry
+	mux       sync.RWMutex
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+type hllEntry struct {
+	hll    hyperLogLog
+	expire int64
+}
+
+func NewMemUniqueCounter(cap int, checkExpInterval time.Duration) MemUniqueCounter {
+	c := &memUniqueCounter{
+		entries: make(map[string]*hllEntry, cap),
+		done:    make(chan struct{}),
+	}
+
+	if checkExpInterval > 0 {
+		go func() {
+			ticker := time.NewTicker(checkExpInterval)
+			defer ticker.Stop()
+
+			for {
+				select {
+				case now := <-ticker.C:
+					c.mux.Lock()
+					for k, v := range c.entries {
+						if v.IsExpired(now) {
+							delete(c.entries, k)
+						}
+					}
+					c.mux.Unlock()
+				case <-c.done:
+					return
+				}
+			}
+		}()
+	}
+	return c
+}
+
+func (m *memUniqueCounter) Add(ctx context.Context, key string, ttl time.Duration, members ...string) (bool, error) {
+	now := time.Now()
+
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	v, created := m.entry(now, key, ttl)
+	changed := created
+	for _, member := range members {
+		if v.hll.add(member) {
+			changed = true
+		}
+	}
+	return changed, nil
+}
+
+func (m *memUniqueCounter) Count(ctx context.Context, keys ...string) (int64, error) {
+	if len(keys) == 0 {
+		return 0, ErrNoKeys
+	}
+	now := time.Now()
+
+	m.mux.RLock()
+	defer m.mux.RUnlock()
+
+	if len(keys) == 1 {
+		v, ok := m.entries[keys[0]]
+		if !ok || v.IsExpired(now) {
+			return 0, nil
+		}
+		return v.hll.count(), nil
+	}
+
+	var union hyperLogLog
+	for _, key := range keys {
+		if v, ok := m.entries[key]; ok && !v.IsExpired(now) {
+			union.merge(&v.hll)
+		}
+	}
+	return union.count(), nil
+}
+
+func (m *memUniqueCounter) Merge(ctx context.Context, dest string, ttl time.Duration, keys ...string) error {
+	now := time.Now()
+
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	d, _ := m.entry(now, dest, ttl)
+	for _, key := range keys {
+		if v, ok := m.entries[key]; ok && v != d && !v.IsExpired(now) {
+			d.hll.merge(&v.hll)
+		}
+	}
+	return nil
+}
+
+// Close 停止后台过期清理，可重复调用
+func (m *memUniqueCounter) Close() error {
+	m.closeOnce.Do(func() {
+		close(m.done)
+	})
+	return nil
+}
+
+// entry 返回 key 对应的 entry，不存在或已过期时新建
+func (m *memUniqueCounter) entry(now time.Time, key string, ttl time.Duration) (*hllEntry, bool) {
+	v, ok := m.entries[key]
+	if ok && !v.IsExpired(now) {
+		return v, false
+	}
+	v = &hllEntry{expire: calcExpire(now, ttl)}
+	m.entries[key] = v
+	return v, true
+}
+
+func (e *hllEntry) IsExpired(now time.Time) bool {
+	if e.expire == 0 || e.expire > now.UnixNano() {
+		return false
+	}
+	return true
+}
@@ -10,22 +10,102 @@ import (
 	"github.com/redis/go-redis/v9"
 )
 
-var _incrCmd = redis.NewScript(`local a=redis.call('INCRBY',KEYS[1],ARGV[1]);if a==tonumber(ARGV[1]) then redis.call('EXPIRE',KEYS[1],ARGV[2]) end;return a`)
-var _hincrCmd = redis.NewScript(`local a=redis.call('HINCRBY',KEYS[1],ARGV[1],ARGV[2]);if a==tonumber(ARGV[2]) then redis.call('EXPIRE',KEYS[1],ARGV[3]) end;return a`)
+var _incrCmd = redis.NewScript(`local a=redis.call('INCRBY',KEYS[1],ARGV[1]);if a==tonumber(ARGV[1]) then redis.call('PEXPIRE',KEYS[1],ARGV[2]) end;return a`)
+var _hincrCmd = redis.NewScript(`local a=redis.call('HINCRBY',KEYS[1],ARGV[1],ARGV[2]);if a==tonumber(ARGV[2]) then redis.call('PEXPIRE',KEYS[1],ARGV[3]) end;return a`)
+
+// KEYS 为 key 及其关联 key；ARGV[1] 为过期毫秒数，不大于 0 时与 EXPIRE 一致删除 key
+var _renewCmd = redis.NewScript(`if redis.call('EXISTS',KEYS[1])==0 then return 0 end;for i=1,#KEYS do redis.call('PEXPIRE',KEYS[i],ARGV[1]) end;return 1`)
+
+// _migrateTop 有序集合不存在时由 group hash 重建，兼容在此之前创建的 group
+const _migrateTop = `
+if redis.call('EXISTS', KEYS[2]) == 0 then
+	local kv = redis.call('HGETALL', KEYS[1])
+	for i = 1, #kv, 2 do
+		redis.call('ZADD', KEYS[2], kv[i + 1], kv[i])
+	end
+	local ttl = redis.call('PTTL', KEYS[1])
+	if ttl > 0 then
+		redis.call('PEXPIRE', KEYS[2], ttl)
+	end
+end
+`
+
+// KEYS[1] group hash，KEYS[2] 有序集合；ARGV: member, step, 过期毫秒数（不大于 0 时不设置）
+var _hincrTopCmd = redis.NewScript(_migrateTop + `
+local a = redis.call('HINCRBY', KEYS[1], ARGV[1], ARGV[2])
+redis.call('ZINCRBY', KEYS[2], ARGV[2], ARGV[1])
+if tonumber(ARGV[3]) > 0 and a == tonumber(ARGV[2]) then
+	redis.call('PEXPIRE', KEYS[1], ARGV[3])
+	redis.call('PEXPIRE', KEYS[2], ARGV[3])
+end
+return a
+`)
+
+// KEYS[1] group hash，KEYS[2] 有序集合；ARGV: k, 是否降序
+var _topCmd = redis.NewScript(_migrateTop + `
+if ARGV[2] == '1' then
+	return redis.call('ZREVRANGE', KEYS[2], 0, ARGV[1] - 1, 'WITHSCORES')
+end
+return redis.call('ZRANGE', KEYS[2], 0, ARGV[1] - 1, 'WITHSCORES')
+`)
+
+// KEYS[1] 窗口 hash，field 为子桶编号；ARGV: step, 当前子桶编号, 子桶个数, 过期毫秒数
+var _windowIncrCmd = redis.NewScript(`
+local cur = tonumber(ARGV[2])
+local low = cur - tonumber(ARGV[3])
+redis.call('HINCRBY', KEYS[1], ARGV[2], ARGV[1])
+local total = 0
+local kv = redis.call('HGETALL', KEYS[1])
+for i = 1, #kv, 2 do
+	local id = tonumber(kv[i])
+	if id > low and id <= cur then
+		total = total + tonumber(kv[i + 1])
+	elseif id <= low then
+		redis.call('HDEL', KEYS[1], kv[i])
+	end
+end
+redis.call('PEXPIRE', KEYS[1], ARGV[4])
+return total
+`)
+
+// KEYS[1] 窗口 hash；ARGV: 当前子桶编号, 子桶个数
+var _windowGetCmd = redis.NewScript(`
+local cur = tonumber(ARGV[1])
+local low = cur - tonumber(ARGV[2])
+local total = 0
+local kv = redis.call('HGETALL', KEYS[1])
+for i = 1, #kv, 2 do
+	local id = tonumber(kv[i])
+	if id > low and id <= cur then
+		total = total + tonumber(kv[i + 1])
+	end
+end
+return total
+`)
 
 type redisCounter struct {
 	rds redis.UniversalClient
+	top bool
 }
 
+// NewRedisCounter TopFromGroup 读取 group 全部成员后排序
 func NewRedisCounter(rds redis.UniversalClient) Counter {
 	return &redisCounter{rds: rds}
 }
 
+// NewRedisCounterWithTop IncrWithGroup 额外维护 group 对应的有序集合 {group}:top，TopFromGroup 直接读取有序集合，
+// 代价是每次自增多一次 ZINCRBY 及一倍的内存。已有 group 在首次访问时由 hash 重建有序集合，
+// 同一 group 不应再通过 NewRedisCounter 写入，否则有序集合不再同步。
+// 有序集合的分值为 float64，计数绝对值超过 2^53 时 TopFromGroup 的排名与 Value 可能不精确
+func NewRedisCounterWithTop(rds redis.UniversalClient) Counter {
+	return &redisCounter{rds: rds, top: true}
+}
+
 func (r *redisCounter) Incr(ctx context.Context, key string, step int64, ttl time.Duration) (int64, error) {
 	if ttl <= 0 {
 		return r.rds.IncrBy(ctx, key, step).Result()
 	}
-	return _incrCmd.Run(ctx, r.rds, []string{key}, step, int(ttl.Seconds())).Int64()
+	return _incrCmd.Run(ctx, r.rds, []string{key}, step, milliseconds(ttl)).Int64()
 }
 
 func (r *redisCounter) Get(ctx context.Context, key string) (int64, error) {
@@ -40,10 +120,101 @@ func (r *redisCounter) Get(ctx context.Context, key string) (int64, error) {
 }
 
 func (r *redisCounter) IncrWithGroup(ctx context.Context, group, member string, step int64, ttl time.Duration) (int64, error) {
+	keys := r.groupKeys(group)
+	if len(keys) > 1 {
+		return _hincrTopCmd.Run(ctx, r.rds, keys, member, step, ttlMilliseconds(ttl)).Int64()
+	}
 	if ttl <= 0 {
 		return r.rds.HIncrBy(ctx, group, member, step).Result()
 	}
-	return _hincrCmd.Run(ctx, r.rds, []string{group}, member, step, int(ttl.Seconds())).Int64()
+	return _hincrCmd.Run(ctx, r.rds, keys, member, step, milliseconds(ttl)).Int64()
+}
+
+// IncrBatch 通过 pipeline 一次性发送，集群模式下由客户端按 slot 拆分到各节点
+func (r *redisCounter) IncrBatch(ctx context.Context, ops []IncrOp) ([]IncrResult, error) {
+	results := make([]IncrResult, len(ops))
+	cmds := make([]redis.Cmder, len(ops))
+	all := make([]int, len(ops))
+	for i := range all {
+		all[i] = i
+	}
+	if err := r.pipeIncr(ctx, ops, all, cmds, true); err != nil {
+		return batchFailed(results, err)
+	}
+
+	// pipeline 中的 EVALSHA 不会自动回退，脚本未加载的操作改用 EVAL 重试
+	var retry []int
+	for i, cmd := range cmds {
+		if redis.HasErrorPrefix(cmd.Err(), "NOSCRIPT") {
+			retry = append(retry, i)
+		}
+	}
+	if len(retry) > 0 {
+		if err := r.pipeIncr(ctx, ops, retry, cmds, false); err != nil {
+			return batchFailed(results, err)
+		}
+	}
+
+	var firstErr error
+	for i, cmd := range cmds {
+		switch c := cmd.(type) {
+		case *redis.IntCmd:
+			results[i].Value, results[i].Err = c.Result()
+		case *redis.Cmd:
+			results[i].Value, results[i].Err = c.Int64()
+		}
+		if results[i].Err != nil && firstErr == nil {
+			firstErr = results[i].Err
+		}
+	}
+	return results, firstErr
+}
+
+// pipeIncr 发送 ops 中下标为 idx 的操作，命令写入 cmds 对应位置；
+// 返回非 redis 错误（如连接失败）时，命令本身不会记录错误
+func (r *redisCounter) pipeIncr(ctx context.Context, ops []IncrOp, idx []int, cmds []redis.Cmder, sha bool) error {
+	_, err := r.rds.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, i := range idx {
+			cmds[i] = r.incrCmder(ctx, pipe, ops[i], sha)
+		}
+		return nil
+	})
+	var rdsErr redis.Error
+	if err != nil && !errors.As(err, &rdsErr) {
+		return err
+	}
+	return nil
+}
+
+func batchFailed(results []IncrResult, err error) ([]IncrResult, error) {
+	for i := range results {
+		results[i].Err = err
+	}
+	return results, err
+}
+
+func (r *redisCounter) incrCmder(ctx context.Context, pipe redis.Pipeliner, op IncrOp, sha bool) redis.Cmder {
+	if op.Member == "" {
+		if op.TTL <= 0 {
+			return pipe.IncrBy(ctx, op.Key, op.Step)
+		}
+		return evalScript(ctx, pipe, _incrCmd, sha, []string{op.Key}, op.Step, milliseconds(op.TTL))
+	}
+	keys := r.groupKeys(op.Key)
+	if len(keys) > 1 {
+		return evalScript(ctx, pipe, _hincrTopCmd, sha, keys, op.Member, op.Step, ttlMilliseconds(op.TTL))
+	}
+	if op.TTL <= 0 {
+		return pipe.HIncrBy(ctx, op.Key, op.Member, op.Step)
+	}
+	return evalScript(ctx, pipe, _hincrCmd, sha, keys, op.Member, op.Step, milliseconds(op.TTL))
+}
+
+func evalScript(ctx context.Context, c redis.Scripter, script *redis.Script, sha bool, keys []string, args ...interface{}) *redis.Cmd {
+	if sha {
+		return script.EvalSha(ctx, c, keys, args...)
+	}
+	return script.Eval(ctx, c, keys, args...)
 }
 
 func (r *redisCounter) GetFromGroup(ctx context.Context, group, member string) (int64, error) {
@@ -95,13 +266,121 @@ func (r *redisCounter) GetAllFromGroup(ctx context.Context, group string) (map[s
 }
 
 func (r *redisCounter) Renew(ctx context.Context, keyOrGroup string, ttl time.Duration) (bool, error) {
-	return r.rds.Expire(ctx, keyOrGroup, ttl).Result()
+	return _renewCmd.Run(ctx, r.rds, r.groupKeys(keyOrGroup), ttlMilliseconds(ttl)).Bool()
+}
+
+func (r *redisCounter) TTL(ctx context.Context, keyOrGroup string) (time.Duration, error) {
+	d, err := r.rds.PTTL(ctx, keyOrGroup).Result()
+	if err != nil {
+		return 0, err
+	}
+	switch d {
+	case -2:
+		return 0, nil
+	case -1:
+		return -1, nil
+	}
+	return d, nil
 }
 
 func (r *redisCounter) Clean(ctx context.Context, keyOrGroup string) error {
-	return r.rds.Del(ctx, keyOrGroup).Err()
+	return r.rds.Del(ctx, r.groupKeys(keyOrGroup)...).Err()
 }
 
 func (r *redisCounter) ResetGroup(ctx context.Context, group string, members ...string) error {
-	return r.rds.HDel(ctx, group, members...).Err()
+	keys := r.groupKeys(group)
+	if len(keys) == 1 {
+		return r.rds.HDel(ctx, group, members...).Err()
+	}
+
+	args := make([]interface{}, len(members))
+	for i, member := range members {
+		args[i] = member
+	}
+	_, err := r.rds.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HDel(ctx, group, members...)
+		pipe.ZRem(ctx, keys[1], args...)
+		return nil
+	})
+	return err
+}
+
+// TopFromGroup 未通过 NewRedisCounterWithTop 创建，或 group 名含 '}' 但没有有效 hash tag 时没有对应的有序集合，
+// 读取全部成员后排序
+func (r *redisCounter) TopFromGroup(ctx context.Context, group string, k int, desc bool) ([]GroupMember, error) {
+	if k <= 0 {
+		return []GroupMember{}, nil
+	}
+
+	keys := r.groupKeys(group)
+	if len(keys) == 1 {
+		m, err := r.GetAllFromGroup(ctx, group)
+		if err != nil {
+			return nil, err
+		}
+		return topMembers(m, k, desc), nil
+	}
+
+	order := 0
+	if desc {
+		order = 1
+	}
+	arr, err := _topCmd.Run(ctx, r.rds, keys, k, order).StringSlice()
+	if err != nil {
+		return nil, err
+	}
+	result := make([]GroupMember, 0, len(arr)/2)
+	for i := 0; i+1 < len(arr); i += 2 {
+		score, err := strconv.ParseFloat(arr[i+1], 64)
+		if err != nil {
+			return nil, fmt.Errorf("redis counter: parse score failed, err: %v", err)
+		}
+		result = append(result, GroupMember{Member: arr[i], Value: int64(score)})
+	}
+	return result, nil
+}
+
+func (r *redisCounter) IncrWindow(ctx context.Context, key string, step int64, window, resolution time.Duration) (int64, error) {
+	return r.incrWindow(ctx, time.Now(), key, step, window, resolution)
+}
+
+func (r *redisCounter) GetWindow(ctx context.Context, key string, window, resolution time.Duration) (int64, error) {
+	return r.getWindow(ctx, time.Now(), key, window, resolution)
+}
+
+func (r *redisCounter) incrWindow(ctx context.Context, now time.Time, key string, step int64, window, resolution time.Duration) (int64, error) {
+	cur, n, err := windowBuckets(now, window, resolution)
+	if err != nil {
+		return 0, err
+	}
+	return _windowIncrCmd.Run(ctx, r.rds, []string{key}, step, cur, n, milliseconds(time.Duration(n)*resolution)).Int64()
+}
+
+func (r *redisCounter) getWindow(ctx context.Context, now time.Time, key string, window, resolution time.Duration) (int64, error) {
+	cur, n, err := windowBuckets(now, window, resolution)
+	if err != nil {
+		return 0, err
+	}
+	return _windowGetCmd.Run(ctx, r.rds, []string{key}, cur, n).Int64()
+}
+
+// groupKeys 未开启有序集合时只返回 group 本身
+func (r *redisCounter) groupKeys(group string) []string {
+	if !r.top {
+		return []string{group}
+	}
+	return groupKeys(group)
+}
+
+// ttlMilliseconds ttl 不大于 0 时返回 0，表示不设置过期时间
+func ttlMilliseconds(ttl time.Duration) int64 {
+	if ttl <= 0 {
+		return 0
+	}
+	return milliseconds(ttl)
+}
+
+// milliseconds 向上取整为毫秒，避免不足 1ms 的 ttl 变为 0 导致 key 被删除
+func milliseconds(d time.Duration) int64 {
+	return int64((d + time.Millisecond - 1) / time.Millisecond)
 }
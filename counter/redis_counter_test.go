@@ -15,6 +15,13 @@ func initRedisCounter() Counter {
 	return NewRedisCounter(rds)
 }
 
+func initRedisTopCounter() Counter {
+	rds := redis.NewUniversalClient(&redis.UniversalOptions{
+		Addrs: []string{"127.0.0.1:6379"},
+	})
+	return NewRedisCounterWithTop(rds)
+}
+
 func TestRedisCounter_Incr(t *testing.T) {
 	counter := initRedisCounter()
 	tests := []struct {
@@ -113,3 +120,54 @@ func TestRedisCounter_IncrWithGroup(t *testing.T) {
 		counter.Clean(ctx, test.group)
 	}
 }
+
+func TestRedisCounter_TopFromGroupLegacy(t *testing.T) {
+	rds := redis.NewUniversalClient(&redis.UniversalOptions{
+		Addrs: []string{"127.0.0.1:6379"},
+	})
+	counter := NewRedisCounterWithTop(rds)
+	ctx := context.Background()
+
+	// 模拟升级前只有 hash 的 group
+	group := "testTopLegacy"
+	_ = counter.Clean(ctx, group)
+	if err := rds.HSet(ctx, group, "a", 2, "b", 7).Err(); err != nil {
+		t.Fatal(err)
+	}
+	_, _ = counter.IncrWithGroup(ctx, group, "a", 10, time.Second)
+
+	top, err := counter.TopFromGroup(ctx, group, 2, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(top) != 2 || top[0] != (GroupMember{"a", 12}) || top[1] != (GroupMember{"b", 7}) {
+		t.Errorf("expect [{a 12} {b 7}], but got %v", top)
+	}
+	_ = counter.Clean(ctx, group)
+}
+
+func TestRedisCounter_TopDisabled(t *testing.T) {
+	rds := redis.NewUniversalClient(&redis.UniversalOptions{
+		Addrs: []string{"127.0.0.1:6379"},
+	})
+	counter := NewRedisCounter(rds)
+	ctx := context.Background()
+
+	group := "testTopDisabled"
+	_ = rds.Del(ctx, group, topKey(group)).Err()
+	_, _ = counter.IncrWithGroup(ctx, group, "a", 1, time.Second)
+	_, _ = counter.IncrBatch(ctx, []IncrOp{{Key: group, Member: "b", Step: 2, TTL: time.Second}})
+
+	n, err := rds.Exists(ctx, topKey(group)).Result()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 0 {
+		t.Errorf("expect 0, but got %d", n)
+	}
+	top, _ := counter.TopFromGroup(ctx, group, 1, true)
+	if len(top) != 1 || top[0] != (GroupMember{"b", 2}) {
+		t.Errorf("expect [{b 2}], but got %v", top)
+	}
+	_ = counter.Clean(ctx, group)
+}
@@ -0,0 +1,75 @@
+package counter
+
+import (
+	"context"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// _uniqueChunk 单次 PFADD/PFMERGE 的参数个数，unpack 参数过多时超出 Lua 栈大小
+const _uniqueChunk = 1000
+
+// ARGV[1] 为过期毫秒数，ARGV[2] 为单批个数，ARGV[3:] 为成员
+var _pfaddCmd = redis.NewScript(`
+local e = redis.call('EXISTS', KEYS[1])
+local n = tonumber(ARGV[2])
+local a = 0
+if #ARGV == 2 then
+	a = redis.call('PFADD', KEYS[1])
+end
+for i = 3, #ARGV, n do
+	if redis.call('PFADD', KEYS[1], unpack(ARGV, i, math.min(i + n - 1, #ARGV))) == 1 then
+		a = 1
+	end
+end
+if e == 0 and tonumber(ARGV[1]) > 0 then
+	redis.call('PEXPIRE', KEYS[1], ARGV[1])
+end
+return a
+`)
+
+// KEYS[1] 为 dest；ARGV[1] 为过期毫秒数，ARGV[2] 为单批个数
+var _pfmergeCmd = redis.NewScript(`
+local e = redis.call('EXISTS', KEYS[1])
+local n = tonumber(ARGV[2])
+if #KEYS == 1 then
+	redis.call('PFMERGE', KEYS[1])
+end
+for i = 2, #KEYS, n do
+	redis.call('PFMERGE', KEYS[1], unpack(KEYS, i, math.min(i + n - 1, #KEYS)))
+end
+if e == 0 and tonumber(ARGV[1]) > 0 then
+	redis.call('PEXPIRE', KEYS[1], ARGV[1])
+end
+return 1
+`)
+
+type redisUniqueCounter struct {
+	rds redis.UniversalClient
+}
+
+// NewRedisUniqueCounter 集群模式下 Count 与 Merge 涉及的 key 需位于同一 slot
+func NewRedisUniqueCounter(rds redis.UniversalClient) UniqueCounter {
+	return &redisUniqueCounter{rds: rds}
+}
+
+func (r *redisUniqueCounter) Add(ctx context.Context, key string, ttl time.Duration, members ...string) (bool, error) {
+	args := make([]interface{}, 0, len(members)+2)
+	args = append(args, ttlMilliseconds(ttl), _uniqueChunk)
+	for _, member := range members {
+		args = append(args, member)
+	}
+	return _pfaddCmd.Run(ctx, r.rds, []string{key}, args...).Bool()
+}
+
+func (r *redisUniqueCounter) Count(ctx context.Context, keys ...string) (int64, error) {
+	if len(keys) == 0 {
+		return 0, ErrNoKeys
+	}
+	return r.rds.PFCount(ctx, keys...).Result()
+}
+
+func (r *redisUniqueCounter) Merge(ctx context.Context, dest string, ttl time.Duration, keys ...string) error {
+	return _pfmergeCmd.Run(ctx, r.rds, append([]string{dest}, keys...), ttlMilliseconds(ttl), _uniqueChunk).Err()
+}
@@ -0,0 +1,340 @@
+package counter
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"os"
+	"time"
+)
+
+// 快照格式：magic | version | entry 数 | entries | crc32(IEEE，覆盖之前的全部字节)
+//
+// entry：key | expire（绝对时间，unix 纳秒，0 表示不过期）| value | 成员数 | 成员 |
+// 是否有滑动窗口，有则依次为 resolution | 子桶数 | 子桶编号与计数。
+// 字符串为 uvarint 长度加内容，整数为 varint
+const (
+	snapshotMagic   = "GUMC"
+	snapshotVersion = 1
+
+	// maxSnapshotLen 限制快照中单个字符串长度与子桶数，避免损坏的数据导致大量内存分配
+	maxSnapshotLen = 1 << 24
+)
+
+var errSnapshotStarted = errors.New("counter: snapshot to file already started")
+
+// Snapshot 将未过期的 entry 写入 w，逐个分片读取，写入 w 期间不持有锁
+func (m *memCounter) Snapshot(w io.Writer) error {
+	var body bytes.Buffer
+	var n int
+	now := time.Now()
+	enc := snapshotEncoder{buf: &body}
+	for _, s := range m.shards {
+		s.mux.RLock()
+		for k, v := range s.entries {
+			if !v.IsExpired(now) {
+				enc.entry(k, v)
+				n++
+			}
+		}
+		s.mux.RUnlock()
+	}
+
+	var buf bytes.Buffer
+	buf.Grow(body.Len() + len(snapshotMagic) + 1 + 2*binary.MaxVarintLen64)
+	buf.WriteString(snapshotMagic)
+	buf.WriteByte(snapshotVersion)
+	enc.buf = &buf
+	enc.uvarint(uint64(n))
+	_, _ = body.WriteTo(&buf)
+
+	_ = binary.Write(&buf, binary.LittleEndian, crc32.ChecksumIEEE(buf.Bytes()))
+	_, err := buf.WriteTo(w)
+	return err
+}
+
+// Restore 读取 Snapshot 写入的数据，覆盖同名 entry，跳过已过期的 entry；
+// 数据校验失败时不修改当前状态
+func (m *memCounter) Restore(r io.Reader) error {
+	dec := snapshotDecoder{r: bufio.NewReader(r), crc: crc32.NewIEEE()}
+
+	magic := make([]byte, len(snapshotMagic))
+	if err := dec.full(magic); err != nil {
+		return err
+	}
+	if string(magic) != snapshotMagic {
+		return errors.New("counter: invalid snapshot: bad magic")
+	}
+	version, err := dec.byte()
+	if err != nil {
+		return err
+	}
+	if version != snapshotVersion {
+		return fmt.Errorf("counter: unsupported snapshot version %d", version)
+	}
+
+	n, err := dec.uvarint()
+	if err != nil {
+		return err
+	}
+	entries := make(map[string]*entry)
+	for i := uint64(0); i < n; i++ {
+		k, v, err := dec.entry()
+		if err != nil {
+			return err
+		}
+		entries[k] = v
+	}
+
+	sum := dec.crc.Sum32()
+	var expect uint32
+	if err = binary.Read(dec.r, binary.LittleEndian, &expect); err != nil {
+		return fmt.Errorf("counter: invalid snapshot: %w", err)
+	}
+	if sum != expect {
+		return errors.New("counter: invalid snapshot: checksum mismatch")
+	}
+
+	now := time.Now()
+	for k, v := range entries {
+		if v.IsExpired(now) {
+			continue
+		}
+		s := m.shard(k)
+		s.mux.Lock()
+		if _, ok := s.entries[k]; !ok {
+			s.evict(now)
+		}
+		s.entries[k] = v
+		s.mux.Unlock()
+	}
+	return nil
+}
+
+// SnapshotToFile 先从 path 恢复（文件不存在时忽略），之后每隔 interval 将快照写入 path，
+// Close 时再写入一次。写入先落到临时文件再重命名，进程崩溃时最多丢失最近一个周期的数据；
+// 恢复失败时返回错误且之后不会写入 path，修复文件后可重新调用
+func (m *memCounter) SnapshotToFile(path string, interval time.Duration) error {
+	// 恢复期间持有锁，Close 等待恢复结束，避免恢复失败前用当前状态覆盖 path
+	m.snapshotMux.Lock()
+	defer m.snapshotMux.Unlock()
+	if m.snapshotPath != "" {
+		return errSnapshotStarted
+	}
+
+	f, err := os.Open(path)
+	if err == nil {
+		err = m.Restore(f)
+		_ = f.Close()
+		if err != nil {
+			return err
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	m.snapshotPath = path
+
+	if interval > 0 {
+		m.snapshotWg.Add(1)
+		go func() {
+			defer m.snapshotWg.Done()
+
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+
+			for {
+				select {
+				case <-ticker.C:
+					_ = m.snapshotFile(path)
+				case <-m.done:
+					return
+				}
+			}
+		}()
+	}
+	return nil
+}
+
+func (m *memCounter) snapshotFile(path string) error {
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if err = m.Snapshot(f); err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+type snapshotEncoder struct {
+	buf *bytes.Buffer
+	tmp [binary.MaxVarintLen64]byte
+}
+
+func (e *snapshotEncoder) uvarint(x uint64) {
+	e.buf.Write(e.tmp[:binary.PutUvarint(e.tmp[:], x)])
+}
+
+func (e *snapshotEncoder) varint(x int64) {
+	e.buf.Write(e.tmp[:binary.PutVarint(e.tmp[:], x)])
+}
+
+func (e *snapshotEncoder) string(s string) {
+	e.uvarint(uint64(len(s)))
+	e.buf.WriteString(s)
+}
+
+func (e *snapshotEncoder) entry(k string, v *entry) {
+	e.string(k)
+	e.varint(v.expire)
+	e.varint(v.value)
+	e.uvarint(uint64(len(v.members)))
+	for member, n := range v.members {
+		e.string(member)
+		e.varint(n)
+	}
+
+	if v.window == nil {
+		e.buf.WriteByte(0)
+		return
+	}
+	e.buf.WriteByte(1)
+	e.varint(int64(v.window.resolution))
+	e.uvarint(uint64(len(v.window.slots)))
+	for i := range v.window.slots {
+		e.varint(v.window.stamps[i])
+		e.varint(v.window.slots[i])
+	}
+}
+
+// snapshotDecoder 读取的字节同时计入 crc
+type snapshotDecoder struct {
+	r   *bufio.Reader
+	crc hash.Hash32
+}
+
+func (d *snapshotDecoder) ReadByte() (byte, error) {
+	b, err := d.r.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+	d.crc.Write([]byte{b})
+	return b, nil
+}
+
+func (d *snapshotDecoder) full(p []byte) error {
+	if _, err := io.ReadFull(d.r, p); err != nil {
+		return fmt.Errorf("counter: invalid snapshot: %w", err)
+	}
+	d.crc.Write(p)
+	return nil
+}
+
+func (d *snapshotDecoder) byte() (byte, error) {
+	b, err := d.ReadByte()
+	if err != nil {
+		return 0, fmt.Errorf("counter: invalid snapshot: %w", err)
+	}
+	return b, nil
+}
+
+func (d *snapshotDecoder) uvarint() (uint64, error) {
+	x, err := binary.ReadUvarint(d)
+	if err != nil {
+		return 0, fmt.Errorf("counter: invalid snapshot: %w", err)
+	}
+	return x, nil
+}
+
+func (d *snapshotDecoder) varint() (int64, error) {
+	x, err := binary.ReadVarint(d)
+	if err != nil {
+		return 0, fmt.Errorf("counter: invalid snapshot: %w", err)
+	}
+	return x, nil
+}
+
+func (d *snapshotDecoder) string() (string, error) {
+	n, err := d.uvarint()
+	if err != nil {
+		return "", err
+	}
+	if n > maxSnapshotLen {
+		return "", fmt.Errorf("counter: invalid snapshot: string length %d too large", n)
+	}
+	p := make([]byte, n)
+	if err = d.full(p); err != nil {
+		return "", err
+	}
+	return string(p), nil
+}
+
+func (d *snapshotDecoder) entry() (string, *entry, error) {
+	k, err := d.string()
+	if err != nil {
+		return "", nil, err
+	}
+	v := &entry{members: make(map[string]int64)}
+	if v.expire, err = d.varint(); err != nil {
+		return "", nil, err
+	}
+	if v.value, err = d.varint(); err != nil {
+		return "", nil, err
+	}
+
+	n, err := d.uvarint()
+	if err != nil {
+		return "", nil, err
+	}
+	for i := uint64(0); i < n; i++ {
+		member, err := d.string()
+		if err != nil {
+			return "", nil, err
+		}
+		if v.members[member], err = d.varint(); err != nil {
+			return "", nil, err
+		}
+	}
+
+	flag, err := d.byte()
+	if err != nil {
+		return "", nil, err
+	}
+	if flag == 0 {
+		return k, v, nil
+	}
+
+	resolution, err := d.varint()
+	if err != nil {
+		return "", nil, err
+	}
+	if n, err = d.uvarint(); err != nil {
+		return "", nil, err
+	}
+	if resolution <= 0 || n == 0 || n > maxSnapshotLen {
+		return "", nil, errors.New("counter: invalid snapshot: bad sliding window")
+	}
+	v.window = newSlidingWindow(int64(n), time.Duration(resolution))
+	for i := range v.window.slots {
+		if v.window.stamps[i], err = d.varint(); err != nil {
+			return "", nil, err
+		}
+		if v.window.slots[i], err = d.varint(); err != nil {
+			return "", nil, err
+		}
+	}
+	return k, v, nil
+}
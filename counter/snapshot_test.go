@@ -0,0 +1,167 @@
+package counter
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMemCounter_Snapshot(t *testing.T) {
+	src := NewMemCounter(10, 0)
+	ctx := context.Background()
+
+	_, _ = src.Incr(ctx, "t1", 3, time.Minute)
+	_, _ = src.Incr(ctx, "t2", -5, -1)
+	_, _ = src.Incr(ctx, "t3", 1, time.Millisecond)
+	_, _ = src.IncrWithGroup(ctx, "g1", "m1", 2, time.Minute)
+	_, _ = src.IncrWithGroup(ctx, "g1", "m2", 7, time.Minute)
+	_, _ = src.IncrWindow(ctx, "w1", 4, time.Minute, time.Second)
+	time.Sleep(2 * time.Millisecond)
+
+	var buf bytes.Buffer
+	if err := src.Snapshot(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	dst := NewMemCounter(10, 0)
+	_, _ = dst.Incr(ctx, "t1", 100, -1)
+	_, _ = dst.Incr(ctx, "other", 1, -1)
+	if err := dst.Restore(bytes.NewReader(buf.Bytes())); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		key    string
+		expect int64
+	}{
+		{"t1", 3},
+		{"t2", -5},
+		{"t3", 0},
+		{"other", 1},
+	}
+	for _, test := range tests {
+		n, _ := dst.Get(ctx, test.key)
+		if n != test.expect {
+			t.Errorf("%s: expect %d, but got %d", test.key, test.expect, n)
+		}
+	}
+	if n := dst.(*memCounter).len(); n != 5 { // t3 已过期被跳过
+		t.Errorf("expect 5 entries, but got %d", n)
+	}
+
+	m, _ := dst.GetAllFromGroup(ctx, "g1")
+	if len(m) != 2 || m["m1"] != 2 || m["m2"] != 7 {
+		t.Errorf("expect m1=2 m2=7, but got %v", m)
+	}
+	n, _ := dst.GetWindow(ctx, "w1", time.Minute, time.Second)
+	if n != 4 {
+		t.Errorf("expect 4, but got %d", n)
+	}
+	d, _ := dst.TTL(ctx, "t1")
+	if d <= 59*time.Second || d > time.Minute {
+		t.Errorf("expect ttl about 1m, but got %s", d)
+	}
+	d, _ = dst.TTL(ctx, "t2")
+	if d != -1 {
+		t.Errorf("expect -1, but got %s", d)
+	}
+}
+
+func TestMemCounter_RestoreInvalid(t *testing.T) {
+	src := NewMemCounter(10, 0)
+	_, _ = src.Incr(context.Background(), "t1", 1, -1)
+	var buf bytes.Buffer
+	_ = src.Snapshot(&buf)
+	data := buf.Bytes()
+
+	corrupt := append([]byte(nil), data...)
+	corrupt[len(corrupt)-7]++ // t1 的 value
+	version := append([]byte(nil), data...)
+	version[len(snapshotMagic)] = snapshotVersion + 1
+
+	tests := []struct {
+		name   string
+		data   []byte
+		expect string
+	}{
+		{"empty", nil, "invalid snapshot"},
+		{"magic", []byte("XXXX"), "bad magic"},
+		{"version", version, "unsupported snapshot version"},
+		{"checksum", corrupt, "checksum mismatch"},
+		{"truncated", data[:len(data)-2], "invalid snapshot"},
+	}
+
+	for _, test := range tests {
+		dst := NewMemCounter(10, 0)
+		err := dst.Restore(bytes.NewReader(test.data))
+		if err == nil || !strings.Contains(err.Error(), test.expect) {
+			t.Errorf("%s: expect error %q, but got %v", test.name, test.expect, err)
+		}
+		if n := dst.(*memCounter).len(); n != 0 {
+			t.Errorf("%s: expect no entries, but got %d", test.name, n)
+		}
+	}
+}
+
+func TestMemCounter_SnapshotToFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "counter.snap")
+	ctx := context.Background()
+
+	c1 := NewMemCounter(10, 0)
+	if err := c1.SnapshotToFile(path, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if err := c1.SnapshotToFile(path, time.Hour); err != errSnapshotStarted {
+		t.Errorf("expect %v, but got %v", errSnapshotStarted, err)
+	}
+	_, _ = c1.Incr(ctx, "t1", 5, time.Minute)
+	_, _ = c1.IncrWithGroup(ctx, "g1", "m1", 2, -1)
+	if err := c1.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	c2 := NewMemCounter(10, 0)
+	defer c2.Close()
+	if err := c2.SnapshotToFile(path, 0); err != nil {
+		t.Fatal(err)
+	}
+	n, _ := c2.Get(ctx, "t1")
+	if n != 5 {
+		t.Errorf("expect 5, but got %d", n)
+	}
+	n, _ = c2.GetFromGroup(ctx, "g1", "m1")
+	if n != 2 {
+		t.Errorf("expect 2, but got %d", n)
+	}
+}
+
+func TestMemCounter_SnapshotToFileCorrupt(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "counter.snap")
+	corrupt := []byte(snapshotMagic + "corrupt")
+	if err := os.WriteFile(path, corrupt, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	c := NewMemCounter(10, 0)
+	if err := c.SnapshotToFile(path, time.Millisecond); err == nil {
+		t.Fatal("expect error, but got nil")
+	}
+	_, _ = c.Incr(context.Background(), "t1", 5, time.Minute)
+	time.Sleep(5 * time.Millisecond)
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 恢复失败的文件保持原样，可修复后重试
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(b, corrupt) {
+		t.Errorf("expect %q, but got %q", corrupt, b)
+	}
+}
@@ -0,0 +1,86 @@
+package counter
+
+import (
+	"container/heap"
+	"strings"
+)
+
+// GroupMember group 中成员及其计数
+type GroupMember struct {
+	Member string
+	Value  int64
+}
+
+// topKey 返回 group 对应的有序集合 key，保证与 group 位于同一 slot；
+// group 含 '}' 但没有有效 hash tag 时无法保证，返回空串
+func topKey(group string) string {
+	if s := strings.IndexByte(group, '{'); s >= 0 {
+		if e := strings.IndexByte(group[s+1:], '}'); e > 0 {
+			return group + ":top"
+		}
+	}
+	if strings.IndexByte(group, '}') >= 0 {
+		return ""
+	}
+	return "{" + group + "}:top"
+}
+
+// groupKeys 返回 group 本身及其有序集合 key
+func groupKeys(group string) []string {
+	if z := topKey(group); z != "" {
+		return []string{group, z}
+	}
+	return []string{group}
+}
+
+// rankBefore 与 redis ZRANGE/ZREVRANGE 的排序一致：计数相同时升序按成员名升序，降序按成员名降序
+func rankBefore(a, b GroupMember, desc bool) bool {
+	if a.Value != b.Value {
+		return a.Value > b.Value == desc
+	}
+	return a.Member > b.Member == desc
+}
+
+// topHeap 堆顶为已选成员中排名最靠后的一个
+type topHeap struct {
+	items []GroupMember
+	desc  bool
+}
+
+func (h *topHeap) Len() int           { return len(h.items) }
+func (h *topHeap) Less(i, j int) bool { return rankBefore(h.items[j], h.items[i], h.desc) }
+func (h *topHeap) Swap(i, j int)      { h.items[i], h.items[j] = h.items[j], h.items[i] }
+func (h *topHeap) Push(x interface{}) { h.items = append(h.items, x.(GroupMember)) }
+func (h *topHeap) Pop() interface{} {
+	n := len(h.items) - 1
+	x := h.items[n]
+	h.items = h.items[:n]
+	return x
+}
+
+// topMembers 返回排名前 k 的成员
+func topMembers(members map[string]int64, k int, desc bool) []GroupMember {
+	if k <= 0 || len(members) == 0 {
+		return []GroupMember{}
+	}
+	if k > len(members) {
+		k = len(members)
+	}
+
+	h := &topHeap{items: make([]GroupMember, 0, k), desc: desc}
+	for member, value := range members {
+		m := GroupMember{Member: member, Value: value}
+		if h.Len() < k {
+			heap.Push(h, m)
+		} else if rankBefore(m, h.items[0], desc) {
+			h.items[0] = m
+			heap.Fix(h, 0)
+		}
+	}
+
+	result := make([]GroupMember, h.Len())
+	for i := len(result) - 1; i >= 0; i-- {
+		result[i] = heap.Pop(h).(GroupMember)
+	}
+	return result
+}
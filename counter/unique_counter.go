@@ -0,0 +1,19 @@
+package counter
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var ErrNoKeys = errors.New("counter: no keys")
+
+// UniqueCounter 基于 HyperLogLog 的去重计数，标准误差约 0.81%
+type UniqueCounter interface {
+	// Add key 新建时设置过期时间，ttl 负数时，不设置过期时间；返回估算值是否可能发生变化
+	Add(ctx context.Context, key string, ttl time.Duration, members ...string) (bool, error)
+	// Count 返回多个 key 并集的去重计数
+	Count(ctx context.Context, keys ...string) (int64, error)
+	// Merge 将 keys 合并到 dest，dest 新建时设置过期时间，ttl 负数时，不设置过期时间
+	Merge(ctx context.Context, dest string, ttl time.Duration, keys ...string) error
+}
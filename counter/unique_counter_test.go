@@ -0,0 +1,156 @@
+package counter
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+func TestUniqueCounter(t *testing.T) {
+	counters := []struct {
+		name    string
+		counter UniqueCounter
+	}{
+		{"mem", NewMemUniqueCounter(10, time.Second)},
+		{"redis", NewRedisUniqueCounter(redis.NewUniversalClient(&redis.UniversalOptions{
+			Addrs: []string{"127.0.0.1:6379"},
+		}))},
+	}
+
+	// 两种实现使用相同的哈希与估算方法，计数应完全一致
+	var expect1, expect2, union hyperLogLog
+	members1 := make([]string, 0, 3000)
+	members2 := make([]string, 0, 3000)
+	for i := 0; i < 3000; i++ {
+		members1 = append(members1, "u"+strconv.Itoa(i))
+		members2 = append(members2, "u"+strconv.Itoa(i+1500))
+		expect1.add(members1[i])
+		expect2.add(members2[i])
+	}
+	union.merge(&expect1)
+	union.merge(&expect2)
+
+	for _, c := range counters {
+		cc := c
+		t.Run(c.name, func(t *testing.T) {
+			ctx := context.Background()
+			keys := []string{"{uv}1", "{uv}2", "{uv}3"}
+			if rc, ok := cc.counter.(*redisUniqueCounter); ok {
+				rc.rds.Del(ctx, keys...)
+			}
+
+			changed, err := cc.counter.Add(ctx, keys[0], time.Second, members1...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !changed {
+				t.Error("expect changed, but got false")
+			}
+			changed, _ = cc.counter.Add(ctx, keys[0], time.Second, members1[:10]...)
+			if changed {
+				t.Error("expect unchanged, but got true")
+			}
+			_, _ = cc.counter.Add(ctx, keys[1], -1, members2...)
+
+			n, err := cc.counter.Count(ctx, keys[0])
+			if err != nil {
+				t.Fatal(err)
+			}
+			if n != expect1.count() {
+				t.Errorf("expect %d, but got %d", expect1.count(), n)
+			}
+			n, _ = cc.counter.Count(ctx, keys[0], keys[1])
+			if n != union.count() {
+				t.Errorf("expect %d, but got %d", union.count(), n)
+			}
+
+			if err = cc.counter.Merge(ctx, keys[2], time.Second, keys[0], keys[1]); err != nil {
+				t.Fatal(err)
+			}
+			n, _ = cc.counter.Count(ctx, keys[2])
+			if n != union.count() {
+				t.Errorf("expect %d, but got %d", union.count(), n)
+			}
+
+			if _, err = cc.counter.Count(ctx); err != ErrNoKeys {
+				t.Errorf("expect %v, but got %v", ErrNoKeys, err)
+			}
+
+			_, _ = cc.counter.Add(ctx, "{uv}4", 5*time.Millisecond, "a")
+			time.Sleep(10 * time.Millisecond)
+			n, _ = cc.counter.Count(ctx, "{uv}4")
+			if n != 0 {
+				t.Errorf("expect 0, but got %d", n)
+			}
+			n, _ = cc.counter.Count(ctx, "{uv}missing")
+			if n != 0 {
+				t.Errorf("expect 0, but got %d", n)
+			}
+
+			if rc, ok := cc.counter.(*redisUniqueCounter); ok {
+				rc.rds.Del(ctx, keys...)
+			}
+		})
+	}
+}
+
+func TestUniqueCounter_ManyMembers(t *testing.T) {
+	counters := []struct {
+		name    string
+		counter UniqueCounter
+	}{
+		{"mem", NewMemUniqueCounter(10, time.Second)},
+		{"redis", NewRedisUniqueCounter(redis.NewUniversalClient(&redis.UniversalOptions{
+			Addrs: []string{"127.0.0.1:6379"},
+		}))},
+	}
+
+	// 成员数超过单批个数，分多次 PFADD
+	var expect hyperLogLog
+	members := make([]string, 0, 20*_uniqueChunk+1)
+	keys := make([]string, 0, 3*_uniqueChunk)
+	for i := 0; i < cap(members); i++ {
+		members = append(members, "u"+strconv.Itoa(i))
+		expect.add(members[i])
+	}
+	for i := 0; i < cap(keys); i++ {
+		keys = append(keys, "{uvMany}"+strconv.Itoa(i))
+	}
+
+	for _, c := range counters {
+		cc := c
+		t.Run(c.name, func(t *testing.T) {
+			ctx := context.Background()
+			if rc, ok := cc.counter.(*redisUniqueCounter); ok {
+				rc.rds.Del(ctx, keys...)
+			}
+
+			changed, err := cc.counter.Add(ctx, keys[0], time.Second, members...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !changed {
+				t.Error("expect changed, but got false")
+			}
+			n, _ := cc.counter.Count(ctx, keys[0])
+			if n != expect.count() {
+				t.Errorf("expect %d, but got %d", expect.count(), n)
+			}
+
+			if err = cc.counter.Merge(ctx, keys[1], time.Second, keys...); err != nil {
+				t.Fatal(err)
+			}
+			n, _ = cc.counter.Count(ctx, keys[1])
+			if n != expect.count() {
+				t.Errorf("expect %d, but got %d", expect.count(), n)
+			}
+
+			if rc, ok := cc.counter.(*redisUniqueCounter); ok {
+				rc.rds.Del(ctx, keys...)
+			}
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package counter
+
+import (
+	"errors"
+	"time"
+)
+
+var ErrInvalidWindow = errors.New("counter: resolution must be at least 1ms and not exceed window")
+
+// windowBuckets 计算 now 所在的子桶编号 cur 以及窗口包含的子桶个数 n，
+// 窗口内的有效子桶编号为 (cur-n, cur]
+func windowBuckets(now time.Time, window, resolution time.Duration) (cur, n int64, err error) {
+	if resolution < time.Millisecond || resolution > window {
+		return 0, 0, ErrInvalidWindow
+	}
+	n = int64(window / resolution)
+	if window%resolution != 0 {
+		n++
+	}
+	return now.UnixNano() / int64(resolution), n, nil
+}
+
+// slidingWindow 子桶环，slots[i] 记录编号为 stamps[i] 的子桶计数
+type slidingWindow struct {
+	resolution time.Duration
+	stamps     []int64
+	slots      []int64
+}
+
+func newSlidingWindow(n int64, resolution time.Duration) *slidingWindow {
+	return &slidingWindow{
+		resolution: resolution,
+		stamps:     make([]int64, n),
+		slots:      make([]int64, n),
+	}
+}
+
+func (w *slidingWindow) match(n int64, resolution time.Duration) bool {
+	return int64(len(w.slots)) == n && w.resolution == resolution
+}
+
+func (w *slidingWindow) incr(cur, step int64) {
+	i := cur % int64(len(w.slots))
+	if w.stamps[i] != cur {
+		w.stamps[i] = cur
+		w.slots[i] = 0
+	}
+	w.slots[i] += step
+}
+
+func (w *slidingWindow) sum(cur int64) int64 {
+	var total int64
+	n := int64(len(w.slots))
+	for i, stamp := range w.stamps {
+		if stamp > cur-n && stamp <= cur {
+			total += w.slots[i]
+		}
+	}
+	return total
+}
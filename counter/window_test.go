@@ -0,0 +1,167 @@
+package counter
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+type windowCounter interface {
+	incrWindow(now time.Time, key string, step int64, window, resolution time.Duration) (int64, error)
+	getWindow(now time.Time, key string, window, resolution time.Duration) (int64, error)
+}
+
+type redisWindowCounter struct {
+	*redisCounter
+}
+
+func (r redisWindowCounter) incrWindow(now time.Time, key string, step int64, window, resolution time.Duration) (int64, error) {
+	return r.redisCounter.incrWindow(context.Background(), now, key, step, window, resolution)
+}
+
+func (r redisWindowCounter) getWindow(now time.Time, key string, window, resolution time.Duration) (int64, error) {
+	return r.redisCounter.getWindow(context.Background(), now, key, window, resolution)
+}
+
+func TestCounter_IncrWindow(t *testing.T) {
+	counters := []struct {
+		name    string
+		counter Counter
+		window  windowCounter
+	}{
+		{"mem", initMemCounter(), nil},
+		{"redis", initRedisCounter(), nil},
+	}
+	counters[0].window = counters[0].counter.(*memCounter)
+	counters[1].window = redisWindowCounter{counters[1].counter.(*redisCounter)}
+
+	// 窗口 1s，子桶 100ms，base 对齐到子桶边界
+	base := time.Now().Truncate(time.Second).Add(time.Minute)
+	tests := []struct {
+		offset time.Duration
+		step   int64
+		expect int64
+	}{
+		{0, 1, 1},
+		{50 * time.Millisecond, 2, 3},
+		{100 * time.Millisecond, 3, 6},
+		{999 * time.Millisecond, 1, 7},
+		{1000 * time.Millisecond, 1, 5},
+		{1150 * time.Millisecond, 0, 2},
+		{1999 * time.Millisecond, 0, 1},
+		{2000 * time.Millisecond, 0, 0},
+		{5000 * time.Millisecond, 4, 4},
+	}
+
+	for _, c := range counters {
+		cc := c
+		t.Run(c.name, func(t *testing.T) {
+			ctx := context.Background()
+			key := "testWindow"
+			_ = cc.counter.Clean(ctx, key)
+			for _, test := range tests {
+				now := base.Add(test.offset)
+				var (
+					n   int64
+					err error
+				)
+				if test.step != 0 {
+					n, err = cc.window.incrWindow(now, key, test.step, time.Second, 100*time.Millisecond)
+				} else {
+					n, err = cc.window.getWindow(now, key, time.Second, 100*time.Millisecond)
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				if n != test.expect {
+					t.Errorf("offset %s: expect %d, but got %d", test.offset, test.expect, n)
+				}
+			}
+			_ = cc.counter.Clean(ctx, key)
+		})
+	}
+}
+
+func TestCounter_GetWindow(t *testing.T) {
+	counters := []struct {
+		name    string
+		counter Counter
+	}{
+		{"mem", initMemCounter()},
+		{"redis", initRedisCounter()},
+	}
+
+	for _, c := range counters {
+		cc := c
+		t.Run(c.name, func(t *testing.T) {
+			ctx := context.Background()
+			key := "testGetWindow"
+			_ = cc.counter.Clean(ctx, key)
+
+			n, err := cc.counter.GetWindow(ctx, key, time.Second, 100*time.Millisecond)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if n != 0 {
+				t.Errorf("expect 0, but got %d", n)
+			}
+
+			for i := 0; i < 5; i++ {
+				if _, err = cc.counter.IncrWindow(ctx, key, 1, time.Second, 100*time.Millisecond); err != nil {
+					t.Fatal(err)
+				}
+			}
+			n, _ = cc.counter.GetWindow(ctx, key, time.Second, 100*time.Millisecond)
+			if n != 5 {
+				t.Errorf("expect 5, but got %d", n)
+			}
+
+			time.Sleep(1100 * time.Millisecond)
+			n, _ = cc.counter.GetWindow(ctx, key, time.Second, 100*time.Millisecond)
+			if n != 0 {
+				t.Errorf("expect 0, but got %d", n)
+			}
+
+			_, err = cc.counter.IncrWindow(ctx, key, 1, time.Millisecond, time.Second)
+			if err != ErrInvalidWindow {
+				t.Errorf("expect %v, but got %v", ErrInvalidWindow, err)
+			}
+			_ = cc.counter.Clean(ctx, key)
+		})
+	}
+}
+
+func TestCounter_WindowThenGroup(t *testing.T) {
+	counters := []struct {
+		name    string
+		counter Counter
+	}{
+		{"mem", initMemCounter()},
+		{"redis", initRedisCounter()},
+	}
+
+	for _, c := range counters {
+		cc := c
+		t.Run(c.name, func(t *testing.T) {
+			ctx := context.Background()
+			key := "testWindowThenGroup"
+			_ = cc.counter.Clean(ctx, key)
+
+			if _, err := cc.counter.IncrWindow(ctx, key, 1, time.Second, 100*time.Millisecond); err != nil {
+				t.Fatal(err)
+			}
+			n, err := cc.counter.IncrWithGroup(ctx, key, "m1", 2, time.Second)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if n != 2 {
+				t.Errorf("expect 2, but got %d", n)
+			}
+			results, _ := cc.counter.IncrBatch(ctx, []IncrOp{{Key: key, Member: "m2", Step: 3, TTL: time.Second}})
+			if results[0].Err != nil || results[0].Value != 3 {
+				t.Errorf("expect 3, but got %+v", results[0])
+			}
+			_ = cc.counter.Clean(ctx, key)
+		})
+	}
+}
@@ -0,0 +1,189 @@
+package protojson
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func TestUnmarshal(t *testing.T) {
+	a, err := anypb.New(&Person{Name: "bob", Like: "book", Age: 18})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := &HelloRequest{
+		Success:   true,
+		Score:     11.2,
+		Age:       18,
+		Timestamp: 1600000000,
+		Data:      []byte("hello world"),
+		Tags:      []string{"hello", "world"},
+		Labels:    map[string]string{"name": "test"},
+		Any:       a,
+	}
+
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{"json names", `{"success":true,"score":11.2,"age":18,"timestamp":"1600000000","data":"aGVsbG8gd29ybGQ=",` +
+			`"tags":["hello","world"],"labels":{"name":"test"},` +
+			`"any":{"@type":"type.googleapis.com/Person","name":"bob","like":"book","age":18}}`},
+		{"int64 as number", `{"success":true,"score":11.2,"age":18,"timestamp":1600000000,"data":"aGVsbG8gd29ybGQ",` +
+			`"tags":["hello","world"],"labels":{"name":"test"},` +
+			`"any":{"name":"bob","age":"18","@type":"type.googleapis.com/Person","like":"book"}}`},
+		{"marshal output", MarshalOptions{Multiline: true}.Format(expect)},
+	}
+
+	for _, test := range tests {
+		var got HelloRequest
+		if err := Unmarshal([]byte(test.input), &got); err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if !proto.Equal(&got, expect) {
+			t.Errorf("%s: expect %v, but got %v", test.name, expect, &got)
+		}
+	}
+}
+
+func TestUnmarshalNames(t *testing.T) {
+	tests := []struct {
+		input  string
+		expect *descriptorpb.FieldDescriptorProto
+	}{
+		{`{"json_name":"a","type":"TYPE_INT64"}`, &descriptorpb.FieldDescriptorProto{
+			JsonName: proto.String("a"),
+			Type:     descriptorpb.FieldDescriptorProto_TYPE_INT64.Enum(),
+		}},
+		{`{"jsonName":"a","type":3}`, &descriptorpb.FieldDescriptorProto{
+			JsonName: proto.String("a"),
+			Type:     descriptorpb.FieldDescriptorProto_TYPE_INT64.Enum(),
+		}},
+		{`{"oneofIndex":null}`, &descriptorpb.FieldDescriptorProto{}},
+	}
+
+	for _, test := range tests {
+		var got descriptorpb.FieldDescriptorProto
+		if err := Unmarshal([]byte(test.input), &got); err != nil {
+			t.Fatalf("%s: %v", test.input, err)
+		}
+		if !proto.Equal(&got, test.expect) {
+			t.Errorf("%s: expect %v, but got %v", test.input, test.expect, &got)
+		}
+	}
+}
+
+func TestUnmarshalWellKnownTypes(t *testing.T) {
+	st, err := structpb.NewStruct(map[string]interface{}{
+		"name": "bob",
+		"tags": []interface{}{"a", true, nil, 1.5},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	anyTs, err := anypb.New(timestamppb.New(time.Unix(1600000000, 1000)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		input  string
+		expect proto.Message
+	}{
+		{`"2020-09-13T20:26:40.000001+08:00"`, &timestamppb.Timestamp{Seconds: 1600000000, Nanos: 1000}},
+		{`"-1.5s"`, &durationpb.Duration{Seconds: -1, Nanos: -500000000}},
+		{`"10"`, wrapperspb.Int64(10)},
+		{`{"name":"bob","tags":["a",true,null,1.5]}`, st},
+		{`{"value":"2020-09-13T12:26:40.000001Z","@type":"type.googleapis.com/google.protobuf.Timestamp"}`, anyTs},
+	}
+
+	for _, test := range tests {
+		got := test.expect.ProtoReflect().New().Interface()
+		if err := Unmarshal([]byte(test.input), got); err != nil {
+			t.Fatalf("%s: %v", test.input, err)
+		}
+		if !proto.Equal(got, test.expect) {
+			t.Errorf("%s: expect %v, but got %v", test.input, test.expect, got)
+		}
+	}
+}
+
+func TestUnmarshalOptions(t *testing.T) {
+	input := []byte(`{"name":"bob","nickname":{"a":[1,2]}}`)
+	if err := Unmarshal(input, &Person{}); err == nil {
+		t.Error("expect unknown field error, but got nil")
+	}
+	var p Person
+	if err := (UnmarshalOptions{DiscardUnknown: true}).Unmarshal(input, &p); err != nil {
+		t.Fatal(err)
+	}
+	if p.Name != "bob" {
+		t.Errorf("expect bob, but got %s", p.Name)
+	}
+
+	// name_part and is_extension are proto2 required fields.
+	input = []byte(`{"namePart":"foo"}`)
+	if err := Unmarshal(input, &descriptorpb.UninterpretedOption_NamePart{}); err == nil {
+		t.Error("expect required field error, but got nil")
+	}
+	if err := (UnmarshalOptions{AllowPartial: true}).Unmarshal(input, &descriptorpb.UninterpretedOption_NamePart{}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestUnmarshalErr(t *testing.T) {
+	tests := []string{
+		``,
+		`{"name":"bob",}`,
+		`{"name":"bob"}{}`,
+		`{"name":"bob","name":"alice"}`,
+		`{"age":"1.5"}`,
+		`{"age":4294967296}`,
+		`{"name":1}`,
+		`{"name":"bob\u00"}`,
+	}
+
+	for _, test := range tests {
+		if err := Unmarshal([]byte(test), &Person{}); err == nil {
+			t.Errorf("%s: expect error, but got nil", test)
+		}
+	}
+}
+
+func TestUnmarshalRecursionLimit(t *testing.T) {
+	deep := []byte(strings.Repeat("[", 2000000))
+	if err := Unmarshal(deep, &structpb.Value{}); err != errRecursionDepth {
+		t.Errorf("expect %v, but got %v", errRecursionDepth, err)
+	}
+
+	// Each nested array costs one level for the Value and one for its list.
+	nested := []byte(`[[[1]]]`)
+	tests := []struct {
+		limit  int
+		expect error
+	}{
+		{0, nil},
+		{7, nil},
+		{6, errRecursionDepth},
+	}
+	for _, test := range tests {
+		err := UnmarshalOptions{RecursionLimit: test.limit}.Unmarshal(nested, &structpb.Value{})
+		if err != test.expect {
+			t.Errorf("limit %d: expect %v, but got %v", test.limit, test.expect, err)
+		}
+	}
+
+	// Skipping a deeply nested unknown field must not exhaust the stack either.
+	unknown := []byte(`{"nickname":` + strings.Repeat("[", 2000000) + strings.Repeat("]", 2000000) + `}`)
+	if err := (UnmarshalOptions{DiscardUnknown: true}).Unmarshal(unknown, &Person{}); err != nil {
+		t.Error(err)
+	}
+}
@@ -2,13 +2,14 @@ package protojson
 
 import (
 	"encoding/base64"
-	"errors"
 	"fmt"
+	"hash"
 	"io"
 
 	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/reflect/protoreflect"
 	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
 )
 
 const (
@@ -16,6 +17,53 @@ const (
 
 	// Full and short names for google.protobuf.NullValue.
 	NullValue_enum_fullname = "google.protobuf.NullValue"
+
+	// Full name for google.protobuf.Value.
+	Value_message_fullname = "google.protobuf.Value"
+
+	// maxSafeInteger is the largest integer that a float64 represents exactly,
+	// that is 2^53-1.
+	maxSafeInteger = 1<<53 - 1
+)
+
+// DefaultMaxDepth is the nesting limit of messages when MaxDepth is 0.
+const DefaultMaxDepth = 10000
+
+// LimitKind is the kind of limit reported by LimitError.
+type LimitKind uint8
+
+const (
+	// LimitDepth is the MaxDepth limit.
+	LimitDepth LimitKind = iota + 1
+	// LimitBytes is the MaxBytes limit.
+	LimitBytes
+)
+
+// LimitError is returned when marshaling exceeds MaxDepth or MaxBytes.
+type LimitError struct {
+	Limit LimitKind
+	// Max is the value of the exceeded limit.
+	Max int
+}
+
+func (e *LimitError) Error() string {
+	if e.Limit == LimitDepth {
+		return fmt.Sprintf("proto: exceeded max depth %d", e.Max)
+	}
+	return fmt.Sprintf("proto: exceeded max bytes %d", e.Max)
+}
+
+// OneofMode is the way EmitUnpopulated emits a oneof of which no field is set.
+type OneofMode uint8
+
+const (
+	// OneofSkip skips the fields of the oneof.
+	OneofSkip OneofMode = iota
+	// OneofNull emits every field of the oneof as null.
+	OneofNull
+	// OneofDefault emits the first declared field of the oneof, the default
+	// case, with its default value. A message field is emitted as {}.
+	OneofDefault
 )
 
 // Format formats the message as a multiline string.
@@ -50,7 +98,8 @@ type MarshalOptions struct {
 	UseEnumNumbers bool
 
 	// EmitUnpopulated specifies whether to emit unpopulated fields. It does not
-	// emit unpopulated oneof fields or unpopulated extension fields.
+	// emit unpopulated oneof fields or unpopulated extension fields, unless
+	// UnpopulatedOneofs or EmitUnpopulatedExtensions is set.
 	// The JSON value emitted for unpopulated fields are as follows:
 	//  ╔═══════╤════════════════════════════╗
 	//  ║ JSON  │ Protobuf field             ║
@@ -65,9 +114,79 @@ type MarshalOptions struct {
 	//  ╚═══════╧════════════════════════════╝
 	EmitUnpopulated bool
 
+	// UnpopulatedOneofs specifies how EmitUnpopulated emits the oneofs of
+	// which no field is set, by default they are skipped. Proto3 optional
+	// fields are such oneofs with a single field.
+	UnpopulatedOneofs OneofMode
+
+	// EmitUnpopulatedExtensions, together with EmitUnpopulated, emits the
+	// extensions of the message that are registered in Resolver but not set.
+	// They are named by "[full.name]" and emitted as null, or as [] for
+	// repeated extensions. The Resolver must be able to list extensions, as
+	// *protoregistry.Types does, otherwise unpopulated extensions are skipped.
+	EmitUnpopulatedExtensions bool
+
+	// Int64AsNumber writes int64, sint64, sfixed64, uint64 and fixed64 values
+	// as JSON numbers instead of strings, including list elements, map values
+	// and the google.protobuf.Int64Value and UInt64Value wrappers. Map keys are
+	// JSON object names and are always written as strings.
+	Int64AsNumber bool
+
+	// Int64SafeRange limits the 64-bit integers written as numbers, either by
+	// Int64AsNumber or by the (json_int64_as_number) field option, to the
+	// range [-(2^53-1), 2^53-1] that a float64 represents exactly. Integers
+	// out of the range are still written as strings.
+	Int64SafeRange bool
+
 	// MessageRanger is a customer field ranger that can be used to iterate over
 	MessageRanger func(message protoreflect.Message) FieldRanger
 
+	// FieldOrder specifies the order to emit message fields in.
+	// If nil, IndexNameFieldOrder is used unless Unsorted is set.
+	FieldOrder FieldOrder
+
+	// MapKeyOrder specifies the order to emit map entries in.
+	// If nil, GenericKeyOrder is used unless Unsorted is set.
+	MapKeyOrder KeyOrder
+
+	// Unsorted emits message fields and map entries in their natural range
+	// order when FieldOrder or MapKeyOrder is nil, which saves the sort cost
+	// at the price of a non-deterministic output.
+	Unsorted bool
+
+	// Canonical produces the RFC 8785 (JCS) style canonical JSON, which is
+	// byte-for-byte stable and suitable for hashing and signatures: object
+	// names are sorted by their UTF-16 code units, there is no insignificant
+	// whitespace, numbers are written in their shortest form and strings are
+	// escaped minimally. It overrides Multiline, Indent, FieldOrder,
+	// MapKeyOrder and Unsorted.
+	Canonical bool
+
+	// FieldMask restricts the output to the fields selected by the dotted
+	// paths of the mask, e.g. "name", "parent.name" or "items.price". A path
+	// segment is the proto name, the JSON name or the (json_name_override) of
+	// a field. Paths may go through nested messages, repeated messages and maps
+	// with message values, in which case the rest of the path applies to every
+	// element, but not through well-known types. Unselected fields are skipped
+	// without being traversed. If nil or empty, all fields are emitted. An
+	// unknown or invalid path fails the marshaling.
+	FieldMask *fieldmaskpb.FieldMask
+
+	// Redact masks the fields marked as sensitive by a field option, see
+	// Redaction. It is applied on top of MessageRanger and EmitUnpopulated.
+	Redact *Redaction
+
+	// MaxDepth limits the nesting of messages, including the messages
+	// embedded in google.protobuf.Any and the well-known types. The top-level
+	// message is at depth 1. If 0, DefaultMaxDepth is used.
+	MaxDepth int
+
+	// MaxBytes limits the size of the output in bytes, 0 means no limit. The
+	// size is checked as fields and list or map elements are written. Since
+	// the output is bounded, MarshalTo buffers it and writes nothing to w
+	// unless the whole message fits.
+	MaxBytes int
+
 	// Resolver is used for looking up types when expanding google.protobuf.Any
 	// messages. If nil, this defaults to using protoregistry.GlobalTypes.
 	Resolver interface {
@@ -86,53 +205,106 @@ func (o MarshalOptions) Format(m proto.Message) string {
 	return string(b)
 }
 
+// Digest writes the canonical JSON of the given proto.Message to h and returns
+// the resulting hash. The message is hashed while it is encoded.
+func Digest(m proto.Message, h hash.Hash) ([]byte, error) {
+	return MarshalOptions{}.Digest(m, h)
+}
+
 // Marshal marshals the given proto.Message in the JSON format using options in MarshalOptions.
 func (o MarshalOptions) Marshal(m proto.Message) ([]byte, error) {
 	return o.marshal(m)
 }
 
+// Digest writes the given proto.Message in the canonical JSON format to h and
+// returns the resulting hash. Canonical is always set.
+func (o MarshalOptions) Digest(m proto.Message, h hash.Hash) ([]byte, error) {
+	o.Canonical = true
+	if err := o.MarshalTo(m, h); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
+
+// MarshalTo writes the given proto.Message in the JSON format to w using options
+// in MarshalOptions. The output is streamed: it is flushed to w in chunks while
+// lists, maps and message fields are traversed, so the whole message is never
+// buffered in memory. Any error returned by w aborts the marshaling.
+//
+// If marshaling fails midway, the chunks already flushed stay written and w
+// holds truncated JSON. Callers that must not emit partial output, such as an
+// HTTP response, should set MaxBytes or marshal with Marshal first.
 func (o MarshalOptions) MarshalTo(m proto.Message, w io.Writer) error {
 	if m == nil {
-		_, _ = w.Write([]byte("{}"))
-		return nil
+		_, err := w.Write([]byte("{}"))
+		return err
 	}
 
-	if o.Multiline && o.Indent == "" {
-		o.Indent = defaultIndent
+	if o.MaxBytes > 0 {
+		b, err := o.marshal(m)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(b)
+		return err
 	}
-	if o.Resolver == nil {
-		o.Resolver = protoregistry.GlobalTypes
+
+	o = o.withDefaults()
+	mask, err := newFieldMaskTree(m.ProtoReflect().Descriptor(), o.FieldMask)
+	if err != nil {
+		return err
 	}
 
-	internalEnc := newEncoder(o.Indent)
-	defer encoderPool.Put(internalEnc)
+	internalEnc := newStreamEncoder(o.Indent, w)
+	defer internalEnc.release()
 
-	enc := encoder{internalEnc, o}
-	if err := enc.marshalMessage(m.ProtoReflect()); err != nil {
+	enc := encoder{Encoder: internalEnc, opts: o, mask: mask, int64AsNumber: o.Int64AsNumber}
+	if err := enc.marshalMessage(m.ProtoReflect(), ""); err != nil {
 		return err
 	}
 
-	_, _ = w.Write(internalEnc.Bytes())
-	return nil
+	return internalEnc.Flush()
 }
 
-func (o MarshalOptions) marshal(m proto.Message) ([]byte, error) {
-	if m == nil {
-		return []byte("{}"), nil
-	}
-
+// withDefaults returns a copy of the options with the defaults filled in.
+func (o MarshalOptions) withDefaults() MarshalOptions {
 	if o.Multiline && o.Indent == "" {
 		o.Indent = defaultIndent
 	}
+	if o.Canonical {
+		// canonical output contains no insignificant whitespace
+		o.Multiline = false
+		o.Indent = ""
+	}
 	if o.Resolver == nil {
 		o.Resolver = protoregistry.GlobalTypes
 	}
+	if o.MaxDepth == 0 {
+		o.MaxDepth = DefaultMaxDepth
+	}
+	return o
+}
+
+func (o MarshalOptions) marshal(m proto.Message) ([]byte, error) {
+	if m == nil {
+		return []byte("{}"), nil
+	}
+
+	o = o.withDefaults()
+	mask, err := newFieldMaskTree(m.ProtoReflect().Descriptor(), o.FieldMask)
+	if err != nil {
+		return nil, err
+	}
 
 	internalEnc := newEncoder(o.Indent)
 	defer encoderPool.Put(internalEnc)
+	internalEnc.maxBytes = o.MaxBytes
 
-	enc := encoder{internalEnc, o}
-	if err := enc.marshalMessage(m.ProtoReflect()); err != nil {
+	enc := encoder{Encoder: internalEnc, opts: o, mask: mask, int64AsNumber: o.Int64AsNumber}
+	if err := enc.marshalMessage(m.ProtoReflect(), ""); err != nil {
+		return nil, err
+	}
+	if err := internalEnc.checkSize(); err != nil {
 		return nil, err
 	}
 
@@ -143,60 +315,151 @@ func (o MarshalOptions) marshal(m proto.Message) ([]byte, error) {
 type encoder struct {
 	*Encoder
 	opts MarshalOptions
+	// int64AsNumber writes 64-bit integers of the current field as numbers.
+	int64AsNumber bool
+	// mask selects the fields of the next message, nil selects all fields.
+	mask *fieldMaskNode
+	// depth is the nesting of the current message.
+	depth int
 }
 
 // unpopulatedFieldRanger wraps a protoreflect.Message and modifies its Range
 // method to additionally iterate over unpopulated fields.
-type unpopulatedFieldRanger struct{ protoreflect.Message }
+type unpopulatedFieldRanger struct {
+	protoreflect.Message
+	// oneofs is how the oneofs of which no field is set are emitted.
+	oneofs OneofMode
+	// extensions lists the registered extensions, nil skips unpopulated
+	// extensions.
+	extensions extensionRanger
+}
+
+// extensionRanger is implemented by resolvers able to list the extensions of
+// a message, such as *protoregistry.Types.
+type extensionRanger interface {
+	RangeExtensionsByMessage(message protoreflect.FullName, f func(protoreflect.ExtensionType) bool)
+}
 
 func (m unpopulatedFieldRanger) Range(f func(protoreflect.FieldDescriptor, protoreflect.Value) bool) {
 	fds := m.Descriptor().Fields()
 	for i := 0; i < fds.Len(); i++ {
 		fd := fds.Get(i)
-		if m.Has(fd) || fd.ContainingOneof() != nil {
-			continue // ignore populated fields and fields within a oneofs
+		if m.Has(fd) {
+			continue // ignore populated fields
 		}
 
 		v := m.Get(fd)
-		isProto2Scalar := fd.Syntax() == protoreflect.Proto2 && fd.Default().IsValid()
-		isSingularMessage := fd.Cardinality() != protoreflect.Repeated && fd.Message() != nil
-		if isProto2Scalar || isSingularMessage {
-			v = protoreflect.Value{} // use invalid value to emit null
+		if od := fd.ContainingOneof(); od != nil {
+			switch {
+			case m.WhichOneof(od) != nil, m.oneofs == OneofSkip:
+				continue // ignore fields within a oneofs
+			case m.oneofs == OneofNull:
+				v = protoreflect.Value{}
+			case fd != od.Fields().Get(0):
+				continue // only the first field is the default case
+			}
+		} else {
+			isProto2Scalar := fd.Syntax() == protoreflect.Proto2 && fd.Default().IsValid()
+			isSingularMessage := fd.Cardinality() != protoreflect.Repeated && fd.Message() != nil
+			if isProto2Scalar || isSingularMessage {
+				v = protoreflect.Value{} // use invalid value to emit null
+			}
 		}
 		if !f(fd, v) {
 			return
 		}
 	}
+
+	if m.extensions != nil {
+		cont := true
+		m.extensions.RangeExtensionsByMessage(m.Descriptor().FullName(), func(xt protoreflect.ExtensionType) bool {
+			xd := xt.TypeDescriptor()
+			if m.Has(xd) {
+				return true
+			}
+			// extensions have presence, only lists are emitted as []
+			v := protoreflect.Value{}
+			if xd.IsList() {
+				v = m.Get(xd)
+			}
+			cont = f(xd, v)
+			return cont
+		})
+		if !cont {
+			return
+		}
+	}
 	m.Message.Range(f)
 }
 
+// fieldRanger returns the FieldRanger of the given message according to the
+// options, the fields are filtered by the mask and redacted.
+func (o MarshalOptions) fieldRanger(m protoreflect.Message, mask *fieldMaskNode) FieldRanger {
+	var fields FieldRanger = m
+	if o.MessageRanger != nil {
+		fields = o.MessageRanger(m)
+	} else if o.EmitUnpopulated {
+		u := unpopulatedFieldRanger{Message: m, oneofs: o.UnpopulatedOneofs}
+		if o.EmitUnpopulatedExtensions {
+			u.extensions, _ = o.Resolver.(extensionRanger)
+		}
+		fields = u
+	}
+	if mask != nil {
+		fields = fieldMaskRanger{fields: fields, mask: mask}
+	}
+	if o.Redact != nil {
+		fields = o.Redact.Ranger(fields)
+	}
+	return fields
+}
+
 // marshalMessage marshals the fields in the given protoreflect.Message.
-func (e encoder) marshalMessage(m protoreflect.Message) error {
-	ok, err := e.checkAndMarshalGoogle(m)
-	if ok {
-		return err
+// If the typeURL is non-empty, then a synthetic "@type" field is injected
+// containing the URL as the value.
+func (e encoder) marshalMessage(m protoreflect.Message, typeURL string) error {
+	if e.depth++; e.depth > e.opts.MaxDepth {
+		return &LimitError{Limit: LimitDepth, Max: e.opts.MaxDepth}
+	}
+	// (json_int64_as_number) applies to integer fields and the value of the
+	// 64-bit integer wrappers only, not to the fields of the nested message
+	// or other well-known types.
+	if !isInt64Wrapper(m.Descriptor().FullName()) {
+		e.int64AsNumber = e.opts.Int64AsNumber
+	}
+	if marshal := wellKnownTypeMarshaler(m.Descriptor().FullName()); marshal != nil {
+		return marshal(e, m)
 	}
 
 	e.StartObject()
 	defer e.EndObject()
 
-	var fields FieldRanger = m
-	if e.opts.MessageRanger != nil {
-		fields = e.opts.MessageRanger(m)
-	} else if e.opts.EmitUnpopulated {
-		fields = unpopulatedFieldRanger{m}
+	if typeURL != "" {
+		if err := e.WriteName(anyTypeFieldName); err != nil {
+			return err
+		}
+		if err := e.WriteString(typeURL); err != nil {
+			return err
+		}
 	}
 
-	RangeFields(fields, IndexNameFieldOrder, func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
-		name := fd.JSONName()
-		if e.opts.UseProtoNames {
-			name = fd.TextName()
+	var err error
+	RangeFields(e.opts.fieldRanger(m, e.mask), e.opts.fieldOrder(), func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		fo := fieldOptionsOf(fd)
+		if fo.omit {
+			return true
 		}
 
-		if err = e.WriteName(name); err != nil {
+		if err = e.WriteName(fieldName(fd, e.opts.UseProtoNames)); err != nil {
+			return false
+		}
+		fe := e
+		fe.int64AsNumber = e.opts.Int64AsNumber || fo.int64AsNumber
+		_, fe.mask = e.mask.selected(fd)
+		if err = fe.marshalValue(v, fd); err != nil {
 			return false
 		}
-		if err = e.marshalValue(v, fd); err != nil {
+		if err = e.flushIfFull(); err != nil {
 			return false
 		}
 		return true
@@ -204,6 +467,54 @@ func (e encoder) marshalMessage(m protoreflect.Message) error {
 	return err
 }
 
+// fieldOrder returns the order to emit message fields in, nil means unsorted.
+func (o MarshalOptions) fieldOrder() FieldOrder {
+	if o.Canonical {
+		if o.UseProtoNames {
+			return canonicalProtoNameFieldOrder
+		}
+		return canonicalJSONNameFieldOrder
+	}
+	if o.FieldOrder != nil || o.Unsorted {
+		return o.FieldOrder
+	}
+	return IndexNameFieldOrder
+}
+
+// mapKeyOrder returns the order to emit map entries in, nil means unsorted.
+func (o MarshalOptions) mapKeyOrder() KeyOrder {
+	if o.Canonical {
+		return canonicalKeyOrder
+	}
+	if o.MapKeyOrder != nil || o.Unsorted {
+		return o.MapKeyOrder
+	}
+	return GenericKeyOrder
+}
+
+// float normalizes the negative zero in canonical mode, which is written
+// out as 0 like ECMAScript does.
+func (e encoder) float(n float64) float64 {
+	if e.opts.Canonical && n == 0 {
+		return 0
+	}
+	return n
+}
+
+// safeInteger reports whether the 64-bit integer may be written as a number
+// under Int64SafeRange.
+func (e encoder) safeInteger(val protoreflect.Value, unsigned bool) bool {
+	switch {
+	case !e.opts.Int64SafeRange:
+		return true
+	case unsigned:
+		return val.Uint() <= maxSafeInteger
+	default:
+		n := val.Int()
+		return n >= -maxSafeInteger && n <= maxSafeInteger
+	}
+}
+
 // marshalValue marshals the given protoreflect.Value.
 func (e encoder) marshalValue(val protoreflect.Value, fd protoreflect.FieldDescriptor) error {
 	switch {
@@ -241,16 +552,25 @@ func (e encoder) marshalSingular(val protoreflect.Value, fd protoreflect.FieldDe
 
 	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Uint64Kind,
 		protoreflect.Sfixed64Kind, protoreflect.Fixed64Kind:
-		// 64-bit integers are written out as JSON string.
-		e.WriteString(val.String())
+		// 64-bit integers are written out as JSON string, unless numbers are
+		// asked for by the options or the field.
+		unsigned := kind == protoreflect.Uint64Kind || kind == protoreflect.Fixed64Kind
+		switch {
+		case !e.int64AsNumber || !e.safeInteger(val, unsigned):
+			e.WriteString(val.String())
+		case unsigned:
+			e.WriteUint(val.Uint())
+		default:
+			e.WriteInt(val.Int())
+		}
 
 	case protoreflect.FloatKind:
 		// Encoder.WriteFloat handles the special numbers NaN and infinites.
-		e.WriteFloat(val.Float(), 32)
+		e.WriteFloat(e.float(val.Float()), 32)
 
 	case protoreflect.DoubleKind:
 		// Encoder.WriteFloat handles the special numbers NaN and infinites.
-		e.WriteFloat(val.Float(), 64)
+		e.WriteFloat(e.float(val.Float()), 64)
 
 	case protoreflect.BytesKind:
 		e.WriteString(base64.StdEncoding.EncodeToString(val.Bytes()))
@@ -268,7 +588,7 @@ func (e encoder) marshalSingular(val protoreflect.Value, fd protoreflect.FieldDe
 		}
 
 	case protoreflect.MessageKind, protoreflect.GroupKind:
-		if err := e.marshalMessage(val.Message()); err != nil {
+		if err := e.marshalMessage(val.Message(), ""); err != nil {
 			return err
 		}
 
@@ -288,6 +608,9 @@ func (e encoder) marshalList(list protoreflect.List, fd protoreflect.FieldDescri
 		if err := e.marshalSingular(item, fd); err != nil {
 			return err
 		}
+		if err := e.flushIfFull(); err != nil {
+			return err
+		}
 	}
 	return nil
 }
@@ -298,71 +621,17 @@ func (e encoder) marshalMap(mmap protoreflect.Map, fd protoreflect.FieldDescript
 	defer e.EndObject()
 
 	var err error
-	RangeEntries(mmap, GenericKeyOrder, func(k protoreflect.MapKey, v protoreflect.Value) bool {
+	RangeEntries(mmap, e.opts.mapKeyOrder(), func(k protoreflect.MapKey, v protoreflect.Value) bool {
 		if err = e.WriteName(k.String()); err != nil {
 			return false
 		}
 		if err = e.marshalSingular(v, fd.MapValue()); err != nil {
 			return false
 		}
+		if err = e.flushIfFull(); err != nil {
+			return false
+		}
 		return true
 	})
 	return err
 }
-
-func (e encoder) checkAndMarshalGoogle(m protoreflect.Message) (bool, error) {
-	name := m.Descriptor().FullName()
-
-	if name.Parent() != "google.protobuf" {
-		return false, nil
-	}
-
-	var err error
-	switch name.Name() {
-	case "Any":
-		err = e.marshalAny(m)
-	default:
-		err = e.WriteString("not support " + string(name))
-	}
-	return true, err
-}
-
-func (e encoder) marshalAny(m protoreflect.Message) error {
-	fds := m.Descriptor().Fields()
-	// fdType := fds.ByName("type_url")
-	// fdValue := fds.ByName("value")
-
-	fdType := fds.ByNumber(1)
-	fdValue := fds.ByNumber(2)
-
-	if !m.Has(fdType) {
-		if !m.Has(fdValue) {
-			// If message is empty, marshal out empty JSON object.
-			e.StartObject()
-			e.EndObject()
-			return nil
-		} else {
-			// Return error if type_url field is not set, but value is set.
-			return errors.New("proto: google.protobuf.Any: type_url is not set")
-		}
-	}
-
-	typeURL := m.Get(fdType).String()
-	valueVal := m.Get(fdValue)
-
-	emt, err := e.opts.Resolver.FindMessageByURL(typeURL)
-	if err != nil {
-		return fmt.Errorf("proto: google.protobuf.Any: unable to resolve %q: %v", typeURL, err)
-	}
-
-	em := emt.New()
-	err = proto.UnmarshalOptions{
-		AllowPartial: true, // never check required fields inside an Any
-		Resolver:     e.opts.Resolver,
-	}.Unmarshal(valueVal.Bytes(), em.Interface())
-	if err != nil {
-		return fmt.Errorf("proto: google.protobuf.Any: unable to unmarshal %q: %v", typeURL, err)
-	}
-
-	return e.marshalMessage(em)
-}
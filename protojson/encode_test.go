@@ -1,11 +1,25 @@
 package protojson
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
 	"fmt"
+	"io"
+	"math"
+	"strconv"
 	"testing"
+	"time"
 
+	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/reflect/protoreflect"
 	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
 )
 
 func TestMarshal(t *testing.T) {
@@ -87,3 +101,336 @@ func (o sensRange) Range(f func(fd protoreflect.FieldDescriptor, val protoreflec
 		return f(descriptor, value)
 	})
 }
+
+func TestMarshalWellKnownTypes(t *testing.T) {
+	st, err := structpb.NewStruct(map[string]interface{}{
+		"name": "bob",
+		"age":  18,
+		"tags": []interface{}{"a", true, nil},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	anyTs, err := anypb.New(timestamppb.New(time.Unix(1600000000, 0)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	anyPerson, err := anypb.New(&Person{Name: "bob", Age: 18})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		input  proto.Message
+		expect string
+	}{
+		{"timestamp", timestamppb.New(time.Unix(1600000000, 0)), `"2020-09-13T12:26:40Z"`},
+		{"timestamp nanos", &timestamppb.Timestamp{Seconds: 1600000000, Nanos: 1000}, `"2020-09-13T12:26:40.000001Z"`},
+		{"duration", durationpb.New(1500 * time.Millisecond), `"1.500s"`},
+		{"negative duration", &durationpb.Duration{Nanos: -1}, `"-0.000000001s"`},
+		{"bool wrapper", wrapperspb.Bool(true), `true`},
+		{"int64 wrapper", wrapperspb.Int64(10), `"10"`},
+		{"string wrapper", wrapperspb.String("hi"), `"hi"`},
+		{"bytes wrapper", wrapperspb.Bytes([]byte("hi")), `"aGk="`},
+		{"struct", st, `{"age":18,"name":"bob","tags":["a",true,null]}`},
+		{"list value", &structpb.ListValue{Values: []*structpb.Value{structpb.NewNumberValue(1.5)}}, `[1.5]`},
+		{"value", structpb.NewStringValue("hi"), `"hi"`},
+		{"field mask", &fieldmaskpb.FieldMask{Paths: []string{"foo_bar", "foo.bar_baz"}}, `"fooBar,foo.barBaz"`},
+		{"empty", &emptypb.Empty{}, `{}`},
+		{"any with well known type", anyTs, `{"@type":"type.googleapis.com/google.protobuf.Timestamp","value":"2020-09-13T12:26:40Z"}`},
+		{"any with message", anyPerson, `{"@type":"type.googleapis.com/Person","name":"bob","age":18}`},
+	}
+
+	for _, test := range tests {
+		b, err := Marshal(test.input)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if string(b) != test.expect {
+			t.Errorf("%s: expect %s, but got %s", test.name, test.expect, b)
+		}
+	}
+}
+
+func TestMarshalWellKnownTypesErr(t *testing.T) {
+	tests := []struct {
+		name  string
+		input proto.Message
+	}{
+		{"timestamp seconds out of range", &timestamppb.Timestamp{Seconds: maxTimestampSeconds + 1}},
+		{"timestamp nanos out of range", &timestamppb.Timestamp{Nanos: -1}},
+		{"duration seconds out of range", &durationpb.Duration{Seconds: maxSecondsInDuration + 1}},
+		{"duration signs mismatch", &durationpb.Duration{Seconds: 1, Nanos: -1}},
+		{"value without kind", &structpb.Value{}},
+		{"value with NaN", structpb.NewNumberValue(math.NaN())},
+		{"field mask with irreversible path", &fieldmaskpb.FieldMask{Paths: []string{"fooBar"}}},
+		{"any without type_url", &anypb.Any{Value: []byte{1}}},
+	}
+
+	for _, test := range tests {
+		if _, err := Marshal(test.input); err == nil {
+			t.Errorf("%s: expect error, but got nil", test.name)
+		}
+	}
+}
+
+type chunkWriter struct {
+	chunks   int
+	maxChunk int
+	buf      []byte
+	failAt   int
+}
+
+func (w *chunkWriter) Write(p []byte) (int, error) {
+	w.chunks++
+	if w.failAt > 0 && w.chunks >= w.failAt {
+		return 0, errors.New("write failed")
+	}
+	if len(p) > w.maxChunk {
+		w.maxChunk = len(p)
+	}
+	w.buf = append(w.buf, p...)
+	return len(p), nil
+}
+
+func newLargeHelloRequest(n int) *HelloRequest {
+	hello := &HelloRequest{
+		Labels: make(map[string]string, n),
+	}
+	for i := 0; i < n; i++ {
+		s := strconv.Itoa(i)
+		hello.Tags = append(hello.Tags, "tag-"+s)
+		hello.Labels["label-"+s] = s
+	}
+	return hello
+}
+
+func TestMarshalTo(t *testing.T) {
+	hello := newLargeHelloRequest(10000)
+	for _, opts := range []MarshalOptions{{}, {Multiline: true}} {
+		expect, err := opts.Marshal(hello)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		w := &chunkWriter{}
+		if err := opts.MarshalTo(hello, w); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(w.buf, expect) {
+			t.Errorf("expect %d bytes, but got %d bytes", len(expect), len(w.buf))
+		}
+		if w.chunks < 2 {
+			t.Errorf("expect output flushed in chunks, but got %d chunks", w.chunks)
+		}
+		if w.maxChunk > 2*flushSize {
+			t.Errorf("expect chunk less than %d bytes, but got %d", 2*flushSize, w.maxChunk)
+		}
+	}
+
+	w := &chunkWriter{failAt: 2}
+	if err := (MarshalOptions{}).MarshalTo(hello, w); err == nil {
+		t.Error("expect write error, but got nil")
+	}
+	if w.chunks != 2 {
+		t.Errorf("expect marshaling stopped at the failed write, but got %d writes", w.chunks)
+	}
+}
+
+func BenchmarkMarshalTo(b *testing.B) {
+	hello := newLargeHelloRequest(10000)
+	b.Run("stream", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if err := (MarshalOptions{}).MarshalTo(hello, io.Discard); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("buffered", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			buf, err := MarshalOptions{}.Marshal(hello)
+			if err != nil {
+				b.Fatal(err)
+			}
+			_, _ = io.Discard.Write(buf)
+		}
+	})
+}
+
+func TestMarshalOrder(t *testing.T) {
+	hello := &HelloRequest{
+		Success: true,
+		Score:   1.5,
+		Age:     18,
+		Data:    []byte("a"),
+		Tags:    []string{"a"},
+		Labels:  map[string]string{"b": "2", "a": "1"},
+	}
+
+	tests := []struct {
+		name   string
+		opts   MarshalOptions
+		expect string
+	}{
+		{"default", MarshalOptions{}, `{"success":true,"score":1.5,"age":18,"data":"YQ==","tags":["a"],"labels":{"a":"1","b":"2"}}`},
+		{"json name", MarshalOptions{FieldOrder: JSONNameFieldOrder}, `{"age":18,"data":"YQ==","labels":{"a":"1","b":"2"},"score":1.5,"success":true,"tags":["a"]}`},
+		{"number", MarshalOptions{FieldOrder: NumberFieldOrder, MapKeyOrder: StringKeyOrder}, `{"success":true,"score":1.5,"age":18,"data":"YQ==","tags":["a"],"labels":{"a":"1","b":"2"}}`},
+		{"reverse", MarshalOptions{
+			FieldOrder:  func(x, y protoreflect.FieldDescriptor) bool { return x.Number() > y.Number() },
+			MapKeyOrder: func(x, y protoreflect.MapKey) bool { return x.String() > y.String() },
+		}, `{"labels":{"b":"2","a":"1"},"tags":["a"],"data":"YQ==","age":18,"score":1.5,"success":true}`},
+	}
+	for _, test := range tests {
+		b, err := test.opts.Marshal(hello)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != test.expect {
+			t.Errorf("%s: expect %s, but got %s", test.name, test.expect, b)
+		}
+	}
+
+	b, err := MarshalOptions{Unsorted: true}.Marshal(hello)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got HelloRequest
+	if err := Unmarshal(b, &got); err != nil {
+		t.Fatal(err)
+	}
+	if !proto.Equal(&got, hello) {
+		t.Errorf("unsorted: expect %v, but got %v", hello, &got)
+	}
+}
+
+func TestMarshalCanonical(t *testing.T) {
+	hello := &HelloRequest{
+		Success: true,
+		Score:   float32(math.Copysign(0, -1)),
+		Age:     18,
+		Tags:    []string{"a", " <\x01"},
+		Labels:  map[string]string{"\U0001F600": "2", "�": "1", "b": "3"},
+	}
+	opts := MarshalOptions{Canonical: true, Multiline: true, Indent: "    ", FieldOrder: NumberFieldOrder}
+	b, err := opts.Marshal(hello)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// U+1F600 is the surrogate pair D83D DE00, which sorts before U+FFFD
+	expect := "{\"age\":18,\"labels\":{\"b\":\"3\",\"\U0001F600\":\"2\",\"�\":\"1\"},\"score\":0,\"success\":true,\"tags\":[\"a\",\" <\\u0001\"]}"
+	if string(b) != expect {
+		t.Errorf("expect %s, but got %s", expect, b)
+	}
+
+	b, err = MarshalOptions{Canonical: true, UseProtoNames: true}.Marshal(&structpb.Struct{Fields: map[string]*structpb.Value{
+		"€":  structpb.NewNumberValue(1e21),
+		"\r": structpb.NewNumberValue(1e-7),
+		"1":  structpb.NewNumberValue(4.5),
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect = `{"\r":1e-7,"1":4.5,"` + "€" + `":1e+21}`
+	if string(b) != expect {
+		t.Errorf("expect %s, but got %s", expect, b)
+	}
+}
+
+func TestDigest(t *testing.T) {
+	hello := newLargeHelloRequest(100)
+	sum, err := Digest(hello, sha256.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := MarshalOptions{Canonical: true}.Marshal(hello)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := sha256.Sum256(b)
+	if !bytes.Equal(sum, expect[:]) {
+		t.Errorf("expect %x, but got %x", expect, sum)
+	}
+
+	sum2, err := MarshalOptions{Multiline: true, FieldOrder: NumberFieldOrder}.Digest(hello, sha256.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(sum, sum2) {
+		t.Errorf("expect %x, but got %x", sum, sum2)
+	}
+}
+
+func TestMarshalMaxDepth(t *testing.T) {
+	var m proto.Message = &Person{Name: "bob"}
+	for i := 0; i < 20; i++ {
+		a, err := anypb.New(m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		m = a
+	}
+
+	tests := []struct {
+		opts MarshalOptions
+		fail bool
+	}{
+		{MarshalOptions{}, false},
+		{MarshalOptions{MaxDepth: 21}, false},
+		{MarshalOptions{MaxDepth: 20}, true},
+		{MarshalOptions{MaxDepth: 5}, true},
+	}
+	for _, test := range tests {
+		_, err := test.opts.Marshal(m)
+		var limitErr *LimitError
+		if test.fail != errors.As(err, &limitErr) {
+			t.Errorf("max depth %d: unexpected error %v", test.opts.MaxDepth, err)
+			continue
+		}
+		if test.fail && (limitErr.Limit != LimitDepth || limitErr.Max != test.opts.MaxDepth) {
+			t.Errorf("max depth %d: unexpected error %v", test.opts.MaxDepth, limitErr)
+		}
+
+		_, err = test.opts.MarshalText(m)
+		if test.fail != errors.As(err, &limitErr) {
+			t.Errorf("max depth %d: unexpected text error %v", test.opts.MaxDepth, err)
+		}
+	}
+}
+
+func TestMarshalMaxBytes(t *testing.T) {
+	hello := newLargeHelloRequest(1000)
+	b, err := Marshal(hello)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := (MarshalOptions{MaxBytes: len(b)}).Marshal(hello); err != nil {
+		t.Errorf("expect no error at the exact size, but got %v", err)
+	}
+	if err := (MarshalOptions{MaxBytes: len(b)}).MarshalTo(hello, io.Discard); err != nil {
+		t.Errorf("expect no error at the exact size, but got %v", err)
+	}
+
+	for _, max := range []int{len(b) - 1, 10000, 100} {
+		opts := MarshalOptions{MaxBytes: max}
+		var limitErr *LimitError
+		if _, err := opts.Marshal(hello); !errors.As(err, &limitErr) || limitErr.Limit != LimitBytes || limitErr.Max != max {
+			t.Errorf("max bytes %d: unexpected error %v", max, err)
+		}
+
+		w := &chunkWriter{}
+		if err := opts.MarshalTo(hello, w); !errors.As(err, &limitErr) {
+			t.Errorf("max bytes %d: unexpected error %v", max, err)
+		}
+		if len(w.buf) != 0 {
+			t.Errorf("max bytes %d: expect nothing written, but got %d bytes", max, len(w.buf))
+		}
+
+		if _, err := opts.MarshalText(hello); !errors.As(err, &limitErr) {
+			t.Errorf("max bytes %d: unexpected text error %v", max, err)
+		}
+	}
+}
@@ -1,6 +1,6 @@
 // Code generated by protoc-gen-go. DO NOT EDIT.
 // versions:
-// 	protoc-gen-go v1.28.0
+// 	protoc-gen-go v1.30.0
 // 	protoc        v3.19.4
 // source: ext.proto
 
@@ -30,12 +30,50 @@ var file_ext_proto_extTypes = []protoimpl.ExtensionInfo{
 		Tag:           "varint,20000,opt,name=sens",
 		Filename:      "ext.proto",
 	},
+	{
+		ExtendedType:  (*descriptorpb.FieldOptions)(nil),
+		ExtensionType: (*string)(nil),
+		Field:         20001,
+		Name:          "json_name_override",
+		Tag:           "bytes,20001,opt,name=json_name_override",
+		Filename:      "ext.proto",
+	},
+	{
+		ExtendedType:  (*descriptorpb.FieldOptions)(nil),
+		ExtensionType: (*bool)(nil),
+		Field:         20002,
+		Name:          "json_omit",
+		Tag:           "varint,20002,opt,name=json_omit",
+		Filename:      "ext.proto",
+	},
+	{
+		ExtendedType:  (*descriptorpb.FieldOptions)(nil),
+		ExtensionType: (*bool)(nil),
+		Field:         20003,
+		Name:          "json_int64_as_number",
+		Tag:           "varint,20003,opt,name=json_int64_as_number",
+		Filename:      "ext.proto",
+	},
 }
 
 // Extension fields to descriptorpb.FieldOptions.
 var (
 	// optional bool sens = 20000;
 	E_Sens = &file_ext_proto_extTypes[0]
+	// json_name_override replaces the JSON name of the field, it is used
+	// whether or not UseProtoNames is set.
+	//
+	// optional string json_name_override = 20001;
+	E_JsonNameOverride = &file_ext_proto_extTypes[1]
+	// json_omit leaves the field out of the JSON wire contract.
+	//
+	// optional bool json_omit = 20002;
+	E_JsonOmit = &file_ext_proto_extTypes[2]
+	// json_int64_as_number writes 64-bit integers as JSON numbers instead
+	// of strings, including the value of Int64Value and UInt64Value fields.
+	//
+	// optional bool json_int64_as_number = 20003;
+	E_JsonInt64AsNumber = &file_ext_proto_extTypes[3]
 )
 
 var File_ext_proto protoreflect.FileDescriptor
@@ -47,10 +85,23 @@ var file_ext_proto_rawDesc = []byte{
 	0x04, 0x73, 0x65, 0x6e, 0x73, 0x12, 0x1d, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70,
 	0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x46, 0x69, 0x65, 0x6c, 0x64, 0x4f, 0x70, 0x74,
 	0x69, 0x6f, 0x6e, 0x73, 0x18, 0xa0, 0x9c, 0x01, 0x20, 0x01, 0x28, 0x08, 0x52, 0x04, 0x73, 0x65,
-	0x6e, 0x73, 0x42, 0x25, 0x5a, 0x23, 0x67, 0x69, 0x74, 0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d,
-	0x2f, 0x77, 0x65, 0x6c, 0x6c, 0x6c, 0x6f, 0x67, 0x2f, 0x67, 0x6f, 0x75, 0x74, 0x69, 0x6c, 0x2f,
-	0x70, 0x72, 0x6f, 0x74, 0x6f, 0x6a, 0x73, 0x6f, 0x6e, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f,
-	0x33,
+	0x6e, 0x73, 0x3a, 0x4d, 0x0a, 0x12, 0x6a, 0x73, 0x6f, 0x6e, 0x5f, 0x6e, 0x61, 0x6d, 0x65, 0x5f,
+	0x6f, 0x76, 0x65, 0x72, 0x72, 0x69, 0x64, 0x65, 0x12, 0x1d, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c,
+	0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x46, 0x69, 0x65, 0x6c, 0x64,
+	0x4f, 0x70, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x18, 0xa1, 0x9c, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52,
+	0x10, 0x6a, 0x73, 0x6f, 0x6e, 0x4e, 0x61, 0x6d, 0x65, 0x4f, 0x76, 0x65, 0x72, 0x72, 0x69, 0x64,
+	0x65, 0x3a, 0x3c, 0x0a, 0x09, 0x6a, 0x73, 0x6f, 0x6e, 0x5f, 0x6f, 0x6d, 0x69, 0x74, 0x12, 0x1d,
+	0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66,
+	0x2e, 0x46, 0x69, 0x65, 0x6c, 0x64, 0x4f, 0x70, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x18, 0xa2, 0x9c,
+	0x01, 0x20, 0x01, 0x28, 0x08, 0x52, 0x08, 0x6a, 0x73, 0x6f, 0x6e, 0x4f, 0x6d, 0x69, 0x74, 0x3a,
+	0x50, 0x0a, 0x14, 0x6a, 0x73, 0x6f, 0x6e, 0x5f, 0x69, 0x6e, 0x74, 0x36, 0x34, 0x5f, 0x61, 0x73,
+	0x5f, 0x6e, 0x75, 0x6d, 0x62, 0x65, 0x72, 0x12, 0x1d, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65,
+	0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x46, 0x69, 0x65, 0x6c, 0x64, 0x4f,
+	0x70, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x18, 0xa3, 0x9c, 0x01, 0x20, 0x01, 0x28, 0x08, 0x52, 0x11,
+	0x6a, 0x73, 0x6f, 0x6e, 0x49, 0x6e, 0x74, 0x36, 0x34, 0x41, 0x73, 0x4e, 0x75, 0x6d, 0x62, 0x65,
+	0x72, 0x42, 0x25, 0x5a, 0x23, 0x67, 0x69, 0x74, 0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f,
+	0x77, 0x65, 0x6c, 0x6c, 0x6c, 0x6f, 0x67, 0x2f, 0x67, 0x6f, 0x75, 0x74, 0x69, 0x6c, 0x2f, 0x70,
+	0x72, 0x6f, 0x74, 0x6f, 0x6a, 0x73, 0x6f, 0x6e, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
 }
 
 var file_ext_proto_goTypes = []interface{}{
@@ -58,10 +109,13 @@ var file_ext_proto_goTypes = []interface{}{
 }
 var file_ext_proto_depIdxs = []int32{
 	0, // 0: sens:extendee -> google.protobuf.FieldOptions
-	1, // [1:1] is the sub-list for method output_type
-	1, // [1:1] is the sub-list for method input_type
-	1, // [1:1] is the sub-list for extension type_name
-	0, // [0:1] is the sub-list for extension extendee
+	0, // 1: json_name_override:extendee -> google.protobuf.FieldOptions
+	0, // 2: json_omit:extendee -> google.protobuf.FieldOptions
+	0, // 3: json_int64_as_number:extendee -> google.protobuf.FieldOptions
+	4, // [4:4] is the sub-list for method output_type
+	4, // [4:4] is the sub-list for method input_type
+	4, // [4:4] is the sub-list for extension type_name
+	0, // [0:4] is the sub-list for extension extendee
 	0, // [0:0] is the sub-list for field type_name
 }
 
@@ -77,7 +131,7 @@ func file_ext_proto_init() {
 			RawDescriptor: file_ext_proto_rawDesc,
 			NumEnums:      0,
 			NumMessages:   0,
-			NumExtensions: 1,
+			NumExtensions: 4,
 			NumServices:   0,
 		},
 		GoTypes:           file_ext_proto_goTypes,
@@ -0,0 +1,127 @@
+package protojson
+
+import (
+	"fmt"
+	"strings"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+)
+
+// fieldMaskNode is a node of the tree built from the paths of a field mask.
+// A nil node or a node without fields selects the whole message.
+type fieldMaskNode struct {
+	fields map[protoreflect.FieldNumber]*fieldMaskNode
+}
+
+// newFieldMaskTree validates the paths of the given field mask against the
+// message descriptor and builds the selection tree. It returns nil if the mask
+// has no paths, which selects the whole message.
+//
+// A path is a dot separated list of field names, each name is either the
+// proto name, the JSON name or the (json_name_override) of a field. Paths may
+// go through singular and repeated message fields as well as maps with message
+// values, the remaining path then applies to every element or map value. Paths
+// may not go through well-known types, which are encoded as a whole.
+func newFieldMaskTree(md protoreflect.MessageDescriptor, mask *fieldmaskpb.FieldMask) (*fieldMaskNode, error) {
+	paths := mask.GetPaths()
+	if len(paths) == 0 {
+		return nil, nil
+	}
+
+	root := &fieldMaskNode{}
+	for _, path := range paths {
+		if err := root.add(md, path); err != nil {
+			return nil, err
+		}
+	}
+	return root, nil
+}
+
+// add adds the given path of the message md to the tree.
+func (n *fieldMaskNode) add(md protoreflect.MessageDescriptor, path string) error {
+	node := n
+	names := strings.Split(path, ".")
+	for i, name := range names {
+		if name == "" {
+			return fmt.Errorf("proto: invalid field mask path %q: empty field name", path)
+		}
+		if wellKnownTypeMarshaler(md.FullName()) != nil {
+			return fmt.Errorf("proto: invalid field mask path %q: cannot select fields of %s", path, md.FullName())
+		}
+
+		fd := fieldByMaskName(md, name)
+		if fd == nil {
+			return fmt.Errorf("proto: invalid field mask path %q: unknown field %q in %s", path, name, md.FullName())
+		}
+
+		if node.fields == nil {
+			node.fields = make(map[protoreflect.FieldNumber]*fieldMaskNode)
+		}
+		child, ok := node.fields[fd.Number()]
+		switch {
+		case ok && child == nil:
+			// the whole field is already selected by a shorter path
+			return nil
+		case i == len(names)-1:
+			// a shorter path selects the whole field
+			node.fields[fd.Number()] = nil
+			return nil
+		case !ok:
+			child = &fieldMaskNode{}
+			node.fields[fd.Number()] = child
+		}
+
+		if fd.IsMap() {
+			fd = fd.MapValue()
+		}
+		if fd.Message() == nil {
+			return fmt.Errorf("proto: invalid field mask path %q: field %s is not a message", path, fd.FullName())
+		}
+		md, node = fd.Message(), child
+	}
+	return nil
+}
+
+// fieldByMaskName returns the field of the message named by a field mask path
+// segment, or nil if there is none.
+func fieldByMaskName(md protoreflect.MessageDescriptor, name string) protoreflect.FieldDescriptor {
+	fds := md.Fields()
+	fd := fieldByOverrideName(md, name)
+	if fd == nil {
+		fd = fds.ByTextName(name)
+	}
+	if fd == nil {
+		fd = fds.ByJSONName(name)
+	}
+	if fd != nil && fieldOptionsOf(fd).omit {
+		return nil
+	}
+	return fd
+}
+
+// selected reports whether the given field is selected, and returns the node
+// selecting its sub fields.
+func (n *fieldMaskNode) selected(fd protoreflect.FieldDescriptor) (bool, *fieldMaskNode) {
+	if n == nil {
+		return true, nil
+	}
+	child, ok := n.fields[fd.Number()]
+	return ok, child
+}
+
+// fieldMaskRanger skips the fields that are not selected by the field mask,
+// the values of unselected fields are never visited.
+type fieldMaskRanger struct {
+	fields FieldRanger
+	mask   *fieldMaskNode
+}
+
+func (o fieldMaskRanger) Range(f VisitField) {
+	o.fields.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		if ok, _ := o.mask.selected(fd); !ok {
+			return true
+		}
+		return f(fd, v)
+	})
+}
@@ -0,0 +1,182 @@
+package protojson
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// newOrderMessage builds the following messages at runtime:
+//
+//	message Item {
+//	  string name = 1;
+//	  int32 price = 2;
+//	  string sku_code = 3;
+//	}
+//	message Order {
+//	  string id = 1;
+//	  Item gift = 2;
+//	  repeated Item items = 3;
+//	  map<string, Item> extras = 4;
+//	  google.protobuf.Timestamp created = 5;
+//	  repeated string tags = 6;
+//	}
+func newOrderMessage(t *testing.T) protoreflect.MessageDescriptor {
+	field := func(name string, num int32, typ descriptorpb.FieldDescriptorProto_Type, typeName string) *descriptorpb.FieldDescriptorProto {
+		fd := &descriptorpb.FieldDescriptorProto{
+			Name:     proto.String(name),
+			JsonName: proto.String(jsonCamelCase(name)),
+			Number:   proto.Int32(num),
+			Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+			Type:     typ.Enum(),
+		}
+		if typeName != "" {
+			fd.TypeName = proto.String(typeName)
+		}
+		return fd
+	}
+	repeated := func(fd *descriptorpb.FieldDescriptorProto) *descriptorpb.FieldDescriptorProto {
+		fd.Label = descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum()
+		return fd
+	}
+
+	fdp := &descriptorpb.FileDescriptorProto{
+		Name:       proto.String("order_test.proto"),
+		Package:    proto.String("order"),
+		Syntax:     proto.String("proto3"),
+		Dependency: []string{"google/protobuf/timestamp.proto"},
+		MessageType: []*descriptorpb.DescriptorProto{{
+			Name: proto.String("Order"),
+			Field: []*descriptorpb.FieldDescriptorProto{
+				field("id", 1, descriptorpb.FieldDescriptorProto_TYPE_STRING, ""),
+				field("gift", 2, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE, ".order.Item"),
+				repeated(field("items", 3, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE, ".order.Item")),
+				repeated(field("extras", 4, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE, ".order.Order.ExtrasEntry")),
+				field("created", 5, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE, ".google.protobuf.Timestamp"),
+				repeated(field("tags", 6, descriptorpb.FieldDescriptorProto_TYPE_STRING, "")),
+			},
+			NestedType: []*descriptorpb.DescriptorProto{{
+				Name: proto.String("ExtrasEntry"),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					field("key", 1, descriptorpb.FieldDescriptorProto_TYPE_STRING, ""),
+					field("value", 2, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE, ".order.Item"),
+				},
+				Options: &descriptorpb.MessageOptions{MapEntry: proto.Bool(true)},
+			}},
+		}, {
+			Name: proto.String("Item"),
+			Field: []*descriptorpb.FieldDescriptorProto{
+				field("name", 1, descriptorpb.FieldDescriptorProto_TYPE_STRING, ""),
+				field("price", 2, descriptorpb.FieldDescriptorProto_TYPE_INT32, ""),
+				field("sku_code", 3, descriptorpb.FieldDescriptorProto_TYPE_STRING, ""),
+			},
+		}},
+	}
+
+	fd, err := protodesc.NewFile(fdp, protoregistry.GlobalFiles)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fd.Messages().Get(0)
+}
+
+func newOrder(md protoreflect.MessageDescriptor) *dynamicpb.Message {
+	fds := md.Fields()
+	itemDesc := fds.ByName("items").Message()
+	newItem := func(name string, price int32) protoreflect.Value {
+		item := dynamicpb.NewMessage(itemDesc)
+		item.Set(itemDesc.Fields().ByName("name"), protoreflect.ValueOfString(name))
+		item.Set(itemDesc.Fields().ByName("price"), protoreflect.ValueOfInt32(price))
+		item.Set(itemDesc.Fields().ByName("sku_code"), protoreflect.ValueOfString("sku-"+name))
+		return protoreflect.ValueOfMessage(item)
+	}
+
+	m := dynamicpb.NewMessage(md)
+	m.Set(fds.ByName("id"), protoreflect.ValueOfString("o1"))
+	m.Set(fds.ByName("gift"), newItem("card", 0))
+	items := m.Mutable(fds.ByName("items")).List()
+	items.Append(newItem("apple", 3))
+	items.Append(newItem("pear", 4))
+	m.Mutable(fds.ByName("extras")).Map().Set(protoreflect.ValueOfString("bag").MapKey(), newItem("bag", 1))
+	m.Set(fds.ByName("created"), protoreflect.ValueOfMessage((&timestamppb.Timestamp{Seconds: 1}).ProtoReflect()))
+	m.Mutable(fds.ByName("tags")).List().Append(protoreflect.ValueOfString("a"))
+	return m
+}
+
+func TestMarshalFieldMask(t *testing.T) {
+	m := newOrder(newOrderMessage(t))
+
+	tests := []struct {
+		paths  []string
+		expect string
+	}{
+		{nil, `{"id":"o1","gift":{"name":"card","skuCode":"sku-card"},"items":[{"name":"apple","price":3,"skuCode":"sku-apple"},{"name":"pear","price":4,"skuCode":"sku-pear"}],"extras":{"bag":{"name":"bag","price":1,"skuCode":"sku-bag"}},"created":"1970-01-01T00:00:01Z","tags":["a"]}`},
+		{[]string{"id", "tags"}, `{"id":"o1","tags":["a"]}`},
+		{[]string{"gift.name", "created"}, `{"gift":{"name":"card"},"created":"1970-01-01T00:00:01Z"}`},
+		{[]string{"items.name", "items.skuCode"}, `{"items":[{"name":"apple","skuCode":"sku-apple"},{"name":"pear","skuCode":"sku-pear"}]}`},
+		{[]string{"extras.price", "items.sku_code"}, `{"items":[{"skuCode":"sku-apple"},{"skuCode":"sku-pear"}],"extras":{"bag":{"price":1}}}`},
+		{[]string{"gift.name", "gift"}, `{"gift":{"name":"card","skuCode":"sku-card"}}`},
+		{[]string{"gift", "gift.name"}, `{"gift":{"name":"card","skuCode":"sku-card"}}`},
+	}
+	for _, test := range tests {
+		opts := MarshalOptions{FieldMask: &fieldmaskpb.FieldMask{Paths: test.paths}}
+		b, err := opts.Marshal(m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != test.expect {
+			t.Errorf("%v: expect %s, but got %s", test.paths, test.expect, b)
+		}
+
+		var buf bytes.Buffer
+		if err := opts.MarshalTo(m, &buf); err != nil {
+			t.Fatal(err)
+		}
+		if buf.String() != test.expect {
+			t.Errorf("%v: expect %s, but got %s", test.paths, test.expect, buf.String())
+		}
+	}
+
+	b, err := MarshalOptions{
+		FieldMask:       &fieldmaskpb.FieldMask{Paths: []string{"id", "gift.price"}},
+		EmitUnpopulated: true,
+	}.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expect := `{"id":"o1","gift":{"price":0}}`; string(b) != expect {
+		t.Errorf("expect %s, but got %s", expect, b)
+	}
+}
+
+func TestMarshalFieldMaskErr(t *testing.T) {
+	m := newOrder(newOrderMessage(t))
+
+	tests := []struct {
+		path   string
+		expect string
+	}{
+		{"", "empty field name"},
+		{"unknown", `unknown field "unknown" in order.Order`},
+		{"gift.unknown", `unknown field "unknown" in order.Item`},
+		{"id.name", "field order.Order.id is not a message"},
+		{"tags.name", "field order.Order.tags is not a message"},
+		{"created.seconds", "cannot select fields of google.protobuf.Timestamp"},
+		{"gift.", "empty field name"},
+	}
+	for _, test := range tests {
+		_, err := MarshalOptions{FieldMask: &fieldmaskpb.FieldMask{Paths: []string{test.path}}}.Marshal(m)
+		if err == nil || !strings.Contains(err.Error(), test.expect) {
+			t.Errorf("%q: expect error %q, but got %v", test.path, test.expect, err)
+		}
+	}
+}
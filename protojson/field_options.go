@@ -0,0 +1,83 @@
+package protojson
+
+import (
+	"sync"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// fieldOptions are the JSON related field options declared in ext.proto.
+type fieldOptions struct {
+	// nameOverride is the value of (json_name_override), empty if unset.
+	nameOverride string
+	// omit is the value of (json_omit).
+	omit bool
+	// int64AsNumber is the value of (json_int64_as_number).
+	int64AsNumber bool
+}
+
+// fieldOptionsCache caches the fieldOptions by protoreflect.FieldDescriptor,
+// so the options message is only inspected once per field.
+var fieldOptionsCache sync.Map
+
+// fieldOptionsOf returns the JSON related options of the given field.
+func fieldOptionsOf(fd protoreflect.FieldDescriptor) fieldOptions {
+	if v, ok := fieldOptionsCache.Load(fd); ok {
+		return v.(fieldOptions)
+	}
+
+	var fo fieldOptions
+	if opts := fd.Options(); opts != nil {
+		fo.nameOverride = proto.GetExtension(opts, E_JsonNameOverride).(string)
+		fo.omit = proto.GetExtension(opts, E_JsonOmit).(bool)
+		fo.int64AsNumber = proto.GetExtension(opts, E_JsonInt64AsNumber).(bool)
+	}
+	fieldOptionsCache.Store(fd, fo)
+	return fo
+}
+
+// isInt64Wrapper reports whether the message is google.protobuf.Int64Value or
+// google.protobuf.UInt64Value, whose value follows (json_int64_as_number) of
+// the field holding it.
+func isInt64Wrapper(name protoreflect.FullName) bool {
+	return name == "google.protobuf.Int64Value" || name == "google.protobuf.UInt64Value"
+}
+
+// fieldName returns the JSON object name of the given field. The
+// (json_name_override) option takes precedence over both the JSON name and
+// the proto name.
+func fieldName(fd protoreflect.FieldDescriptor, useProtoNames bool) string {
+	if name := fieldOptionsOf(fd).nameOverride; name != "" {
+		return name
+	}
+	if useProtoNames {
+		return fd.TextName()
+	}
+	return fd.JSONName()
+}
+
+// overriddenFields caches the fields renamed by (json_name_override) by
+// protoreflect.MessageDescriptor, as map[string]protoreflect.FieldDescriptor.
+var overriddenFields sync.Map
+
+// fieldByOverrideName returns the field of the given message renamed to name
+// by (json_name_override), or nil if there is none.
+func fieldByOverrideName(md protoreflect.MessageDescriptor, name string) protoreflect.FieldDescriptor {
+	v, ok := overriddenFields.Load(md)
+	if !ok {
+		var names map[string]protoreflect.FieldDescriptor
+		fds := md.Fields()
+		for i := 0; i < fds.Len(); i++ {
+			fd := fds.Get(i)
+			if override := fieldOptionsOf(fd).nameOverride; override != "" {
+				if names == nil {
+					names = make(map[string]protoreflect.FieldDescriptor)
+				}
+				names[override] = fd
+			}
+		}
+		v, _ = overriddenFields.LoadOrStore(md, names)
+	}
+	return v.(map[string]protoreflect.FieldDescriptor)[name]
+}
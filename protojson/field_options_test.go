@@ -0,0 +1,298 @@
+package protojson
+
+import (
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// newLegacyMessage builds the following message at runtime:
+//
+//	message Legacy {
+//	  string user_name = 1 [(json_name_override) = "UserName"];
+//	  string password = 2 [(json_omit) = true];
+//	  int64 id = 3 [(json_int64_as_number) = true];
+//	  repeated uint64 ids = 4 [(json_int64_as_number) = true];
+//	  map<string, sint64> totals = 5 [(json_int64_as_number) = true];
+//	  int64 plain = 6;
+//	  map<int64, uint64> counts = 7;
+//	}
+func newLegacyMessage(t *testing.T) protoreflect.MessageDescriptor {
+	option := func(xt protoreflect.ExtensionType, v interface{}) *descriptorpb.FieldOptions {
+		opts := &descriptorpb.FieldOptions{}
+		proto.SetExtension(opts, xt, v)
+		return opts
+	}
+	field := func(name string, num int32, typ descriptorpb.FieldDescriptorProto_Type, opts *descriptorpb.FieldOptions) *descriptorpb.FieldDescriptorProto {
+		return &descriptorpb.FieldDescriptorProto{
+			Name:     proto.String(name),
+			JsonName: proto.String(jsonCamelCase(name)),
+			Number:   proto.Int32(num),
+			Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+			Type:     typ.Enum(),
+			Options:  opts,
+		}
+	}
+
+	ids := field("ids", 4, descriptorpb.FieldDescriptorProto_TYPE_UINT64, option(E_JsonInt64AsNumber, true))
+	ids.Label = descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum()
+	totals := field("totals", 5, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE, option(E_JsonInt64AsNumber, true))
+	totals.Label = descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum()
+	totals.TypeName = proto.String(".legacy.Legacy.TotalsEntry")
+	counts := field("counts", 7, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE, nil)
+	counts.Label = descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum()
+	counts.TypeName = proto.String(".legacy.Legacy.CountsEntry")
+
+	fdp := &descriptorpb.FileDescriptorProto{
+		Name:       proto.String("legacy_test.proto"),
+		Package:    proto.String("legacy"),
+		Syntax:     proto.String("proto3"),
+		Dependency: []string{"ext.proto"},
+		MessageType: []*descriptorpb.DescriptorProto{{
+			Name: proto.String("Legacy"),
+			Field: []*descriptorpb.FieldDescriptorProto{
+				field("user_name", 1, descriptorpb.FieldDescriptorProto_TYPE_STRING, option(E_JsonNameOverride, "UserName")),
+				field("password", 2, descriptorpb.FieldDescriptorProto_TYPE_STRING, option(E_JsonOmit, true)),
+				field("id", 3, descriptorpb.FieldDescriptorProto_TYPE_INT64, option(E_JsonInt64AsNumber, true)),
+				ids,
+				totals,
+				field("plain", 6, descriptorpb.FieldDescriptorProto_TYPE_INT64, nil),
+				counts,
+			},
+			NestedType: []*descriptorpb.DescriptorProto{{
+				Name: proto.String("TotalsEntry"),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					field("key", 1, descriptorpb.FieldDescriptorProto_TYPE_STRING, nil),
+					field("value", 2, descriptorpb.FieldDescriptorProto_TYPE_SINT64, nil),
+				},
+				Options: &descriptorpb.MessageOptions{MapEntry: proto.Bool(true)},
+			}, {
+				Name: proto.String("CountsEntry"),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					field("key", 1, descriptorpb.FieldDescriptorProto_TYPE_INT64, nil),
+					field("value", 2, descriptorpb.FieldDescriptorProto_TYPE_UINT64, nil),
+				},
+				Options: &descriptorpb.MessageOptions{MapEntry: proto.Bool(true)},
+			}},
+		}},
+	}
+
+	fd, err := protodesc.NewFile(fdp, protoregistry.GlobalFiles)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fd.Messages().Get(0)
+}
+
+// newWrappedMessage builds the following message at runtime:
+//
+//	message Wrapped {
+//	  google.protobuf.Int64Value id = 1 [(json_int64_as_number) = true];
+//	  repeated google.protobuf.UInt64Value ids = 2 [(json_int64_as_number) = true];
+//	  google.protobuf.Int64Value plain = 3;
+//	}
+func newWrappedMessage(t *testing.T) protoreflect.MessageDescriptor {
+	opts := &descriptorpb.FieldOptions{}
+	proto.SetExtension(opts, E_JsonInt64AsNumber, true)
+	field := func(name string, num int32, label descriptorpb.FieldDescriptorProto_Label, typeName string, opts *descriptorpb.FieldOptions) *descriptorpb.FieldDescriptorProto {
+		return &descriptorpb.FieldDescriptorProto{
+			Name:     proto.String(name),
+			JsonName: proto.String(jsonCamelCase(name)),
+			Number:   proto.Int32(num),
+			Label:    label.Enum(),
+			Type:     descriptorpb.FieldDescriptorProto_TYPE_MESSAGE.Enum(),
+			TypeName: proto.String(typeName),
+			Options:  opts,
+		}
+	}
+
+	fdp := &descriptorpb.FileDescriptorProto{
+		Name:       proto.String("wrapped_test.proto"),
+		Package:    proto.String("wrapped"),
+		Syntax:     proto.String("proto3"),
+		Dependency: []string{"ext.proto", "google/protobuf/wrappers.proto"},
+		MessageType: []*descriptorpb.DescriptorProto{{
+			Name: proto.String("Wrapped"),
+			Field: []*descriptorpb.FieldDescriptorProto{
+				field("id", 1, descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL, ".google.protobuf.Int64Value", opts),
+				field("ids", 2, descriptorpb.FieldDescriptorProto_LABEL_REPEATED, ".google.protobuf.UInt64Value", opts),
+				field("plain", 3, descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL, ".google.protobuf.Int64Value", nil),
+			},
+		}},
+	}
+
+	fd, err := protodesc.NewFile(fdp, protoregistry.GlobalFiles)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fd.Messages().Get(0)
+}
+
+func newLegacy(md protoreflect.MessageDescriptor) *dynamicpb.Message {
+	fds := md.Fields()
+	m := dynamicpb.NewMessage(md)
+	m.Set(fds.ByName("user_name"), protoreflect.ValueOfString("bob"))
+	m.Set(fds.ByName("password"), protoreflect.ValueOfString("123456"))
+	m.Set(fds.ByName("id"), protoreflect.ValueOfInt64(-9007199254740993))
+	ids := m.Mutable(fds.ByName("ids")).List()
+	ids.Append(protoreflect.ValueOfUint64(1))
+	ids.Append(protoreflect.ValueOfUint64(18446744073709551615))
+	m.Mutable(fds.ByName("totals")).Map().Set(protoreflect.ValueOfString("a").MapKey(), protoreflect.ValueOfInt64(-2))
+	m.Set(fds.ByName("plain"), protoreflect.ValueOfInt64(7))
+	counts := m.Mutable(fds.ByName("counts")).Map()
+	counts.Set(protoreflect.ValueOfInt64(-9007199254740992).MapKey(), protoreflect.ValueOfUint64(9007199254740991))
+	counts.Set(protoreflect.ValueOfInt64(3).MapKey(), protoreflect.ValueOfUint64(9007199254740992))
+	return m
+}
+
+func TestMarshalFieldOptions(t *testing.T) {
+	md := newLegacyMessage(t)
+	m := newLegacy(md)
+
+	tests := []struct {
+		name   string
+		opts   MarshalOptions
+		expect string
+	}{
+		{"default", MarshalOptions{}, `{"UserName":"bob","id":-9007199254740993,"ids":[1,18446744073709551615],"totals":{"a":-2},"plain":"7","counts":{"-9007199254740992":"9007199254740991","3":"9007199254740992"}}`},
+		{"proto names", MarshalOptions{UseProtoNames: true}, `{"UserName":"bob","id":-9007199254740993,"ids":[1,18446744073709551615],"totals":{"a":-2},"plain":"7","counts":{"-9007199254740992":"9007199254740991","3":"9007199254740992"}}`},
+		{"unpopulated", MarshalOptions{EmitUnpopulated: true}, `{"UserName":"bob","id":-9007199254740993,"ids":[1,18446744073709551615],"totals":{"a":-2},"plain":"7","counts":{"-9007199254740992":"9007199254740991","3":"9007199254740992"}}`},
+		{"json name order", MarshalOptions{FieldOrder: JSONNameFieldOrder}, `{"UserName":"bob","counts":{"-9007199254740992":"9007199254740991","3":"9007199254740992"},"id":-9007199254740993,"ids":[1,18446744073709551615],"plain":"7","totals":{"a":-2}}`},
+		{"canonical", MarshalOptions{Canonical: true}, `{"UserName":"bob","counts":{"-9007199254740992":"9007199254740991","3":"9007199254740992"},"id":-9007199254740993,"ids":[1,18446744073709551615],"plain":"7","totals":{"a":-2}}`},
+	}
+	for _, test := range tests {
+		b, err := test.opts.Marshal(m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != test.expect {
+			t.Errorf("%s: expect %s, but got %s", test.name, test.expect, b)
+		}
+	}
+}
+
+func TestUnmarshalFieldOptions(t *testing.T) {
+	md := newLegacyMessage(t)
+	m := newLegacy(md)
+	b, err := Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := dynamicpb.NewMessage(md)
+	if err := Unmarshal(b, got); err != nil {
+		t.Fatal(err)
+	}
+	m.Clear(md.Fields().ByName("password"))
+	if !proto.Equal(got, m) {
+		t.Errorf("expect %v, but got %v", m, got)
+	}
+
+	got = dynamicpb.NewMessage(md)
+	if err := Unmarshal([]byte(`{"userName":"bob","user_name":"alice"}`), got); err == nil {
+		t.Errorf("expect duplicate field error")
+	}
+	if err := Unmarshal([]byte(`{"password":"123456"}`), got); err == nil {
+		t.Errorf("expect unknown field error")
+	}
+	if err := (UnmarshalOptions{DiscardUnknown: true}).Unmarshal([]byte(`{"password":"123456"}`), got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Has(md.Fields().ByName("password")) {
+		t.Errorf("expect password to be omitted")
+	}
+}
+
+func TestMarshalInt64AsNumber(t *testing.T) {
+	md := newLegacyMessage(t)
+	m := newLegacy(md)
+
+	tests := []struct {
+		name   string
+		opts   MarshalOptions
+		m      proto.Message
+		expect string
+	}{
+		{"number", MarshalOptions{Int64AsNumber: true}, m, `{"UserName":"bob","id":-9007199254740993,"ids":[1,18446744073709551615],"totals":{"a":-2},"plain":7,"counts":{"-9007199254740992":9007199254740991,"3":9007199254740992}}`},
+		{"safe range", MarshalOptions{Int64AsNumber: true, Int64SafeRange: true}, m, `{"UserName":"bob","id":"-9007199254740993","ids":[1,"18446744073709551615"],"totals":{"a":-2},"plain":7,"counts":{"-9007199254740992":9007199254740991,"3":"9007199254740992"}}`},
+		{"field option safe range", MarshalOptions{Int64SafeRange: true}, m, `{"UserName":"bob","id":"-9007199254740993","ids":[1,"18446744073709551615"],"totals":{"a":-2},"plain":"7","counts":{"-9007199254740992":"9007199254740991","3":"9007199254740992"}}`},
+		{"wrapper", MarshalOptions{Int64AsNumber: true}, wrapperspb.Int64(-5), `-5`},
+		{"wrapper safe range", MarshalOptions{Int64AsNumber: true, Int64SafeRange: true}, wrapperspb.UInt64(1 << 60), `"1152921504606846976"`},
+		{"any", MarshalOptions{Int64AsNumber: true}, mustAny(t, &HelloRequest{Timestamp: 10}), `{"@type":"type.googleapis.com/HelloRequest","timestamp":10}`},
+	}
+	for _, test := range tests {
+		b, err := test.opts.Marshal(test.m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != test.expect {
+			t.Errorf("%s: expect %s, but got %s", test.name, test.expect, b)
+		}
+	}
+
+	b, err := MarshalOptions{Int64AsNumber: true}.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := dynamicpb.NewMessage(md)
+	if err := Unmarshal(b, got); err != nil {
+		t.Fatal(err)
+	}
+	m.Clear(md.Fields().ByName("password"))
+	if !proto.Equal(got, m) {
+		t.Errorf("expect %v, but got %v", m, got)
+	}
+}
+
+func TestMarshalInt64WrapperOption(t *testing.T) {
+	md := newWrappedMessage(t)
+	fds := md.Fields()
+	m := dynamicpb.NewMessage(md)
+	m.Set(fds.ByName("id"), protoreflect.ValueOfMessage(wrapperspb.Int64(-5).ProtoReflect()))
+	ids := m.Mutable(fds.ByName("ids")).List()
+	ids.Append(protoreflect.ValueOfMessage(wrapperspb.UInt64(1).ProtoReflect()))
+	ids.Append(protoreflect.ValueOfMessage(wrapperspb.UInt64(1 << 60).ProtoReflect()))
+	m.Set(fds.ByName("plain"), protoreflect.ValueOfMessage(wrapperspb.Int64(7).ProtoReflect()))
+
+	tests := []struct {
+		name   string
+		opts   MarshalOptions
+		expect string
+	}{
+		{"default", MarshalOptions{}, `{"id":-5,"ids":[1,1152921504606846976],"plain":"7"}`},
+		{"number", MarshalOptions{Int64AsNumber: true}, `{"id":-5,"ids":[1,1152921504606846976],"plain":7}`},
+		{"safe range", MarshalOptions{Int64SafeRange: true}, `{"id":-5,"ids":[1,"1152921504606846976"],"plain":"7"}`},
+	}
+	for _, test := range tests {
+		b, err := test.opts.Marshal(m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != test.expect {
+			t.Errorf("%s: expect %s, but got %s", test.name, test.expect, b)
+		}
+
+		got := dynamicpb.NewMessage(md)
+		if err := Unmarshal(b, got); err != nil {
+			t.Fatal(err)
+		}
+		if !proto.Equal(got, m) {
+			t.Errorf("%s: expect %v, but got %v", test.name, m, got)
+		}
+	}
+}
+
+func mustAny(t *testing.T, m proto.Message) *anypb.Any {
+	a, err := anypb.New(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a
+}
@@ -2,6 +2,7 @@ package protojson
 
 import (
 	"errors"
+	"io"
 	"math"
 	"math/bits"
 	"strconv"
@@ -23,6 +24,10 @@ const (
 	arrayClose
 )
 
+// flushSize is the size of the buffered output at which a streaming Encoder
+// writes the output to its writer.
+const flushSize = 4 << 10
+
 var encoderPool sync.Pool
 
 // Encoder provides methods to write out JSON constructs and values. The user is
@@ -32,6 +37,11 @@ type Encoder struct {
 	lastKind kind
 	indents  []byte
 	out      []byte
+	w        io.Writer
+	// maxBytes limits the size of the output, 0 means no limit.
+	maxBytes int
+	// flushed is the number of bytes written to w.
+	flushed int
 }
 
 // newEncoder returns a new encoder with the given indent string.
@@ -50,10 +60,62 @@ func newEncoder(indent string) *Encoder {
 	e.lastKind = 0
 	e.indents = e.indents[:0]
 	e.out = e.out[:0]
+	e.w = nil
+	e.maxBytes = 0
+	e.flushed = 0
+
+	return e
+}
 
+// newStreamEncoder returns a new encoder which flushes the output to w.
+func newStreamEncoder(indent string, w io.Writer) *Encoder {
+	e := newEncoder(indent)
+	e.w = w
 	return e
 }
 
+// release puts the encoder back to the pool. The writer is dropped so that the
+// pool does not keep it alive.
+func (e *Encoder) release() {
+	e.w = nil
+	encoderPool.Put(e)
+}
+
+// Flush writes the buffered output to the writer of a streaming Encoder and
+// resets the buffer. It does nothing if the Encoder has no writer.
+func (e *Encoder) Flush() error {
+	if e.w == nil || len(e.out) == 0 {
+		return nil
+	}
+	if err := e.checkSize(); err != nil {
+		return err
+	}
+	n, err := e.w.Write(e.out)
+	e.flushed += n
+	e.out = e.out[:0]
+	return err
+}
+
+// flushIfFull checks the size of the output and flushes the buffered output
+// once it reaches flushSize.
+func (e *Encoder) flushIfFull() error {
+	if err := e.checkSize(); err != nil {
+		return err
+	}
+	if e.w == nil || len(e.out) < flushSize {
+		return nil
+	}
+	return e.Flush()
+}
+
+// checkSize returns a *LimitError if the output exceeds maxBytes.
+func (e *Encoder) checkSize() error {
+	if e.maxBytes > 0 && e.flushed+len(e.out) > e.maxBytes {
+		return &LimitError{Limit: LimitBytes, Max: e.maxBytes}
+	}
+	return nil
+}
+
 // Bytes returns the content of the written bytes.
 func (e *Encoder) Bytes() []byte {
 	return e.out
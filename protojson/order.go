@@ -3,6 +3,9 @@ package protojson
 import (
 	"sort"
 	"sync"
+	"unicode"
+	"unicode/utf16"
+	"unicode/utf8"
 
 	"google.golang.org/protobuf/reflect/protoreflect"
 )
@@ -66,6 +69,56 @@ var (
 		return x.Index() < y.Index()
 	}
 
+	// NumberFieldOrder sorts non-extension fields before extension fields.
+	// Non-extensions are sorted according to their field number.
+	// Extensions are sorted according to their full name.
+	NumberFieldOrder FieldOrder = func(x, y protoreflect.FieldDescriptor) bool {
+		// Non-extension fields sort before extension fields.
+		if x.IsExtension() != y.IsExtension() {
+			return !x.IsExtension() && y.IsExtension()
+		}
+		// Extensions sorted by fullname.
+		if x.IsExtension() && y.IsExtension() {
+			return x.FullName() < y.FullName()
+		}
+		// Non-extensions sorted by field number.
+		return x.Number() < y.Number()
+	}
+
+	// JSONNameFieldOrder sorts non-extension fields before extension fields.
+	// Non-extensions are sorted according to their JSON name, or the
+	// (json_name_override) if it is set.
+	// Extensions are sorted according to their full name.
+	JSONNameFieldOrder FieldOrder = func(x, y protoreflect.FieldDescriptor) bool {
+		// Non-extension fields sort before extension fields.
+		if x.IsExtension() != y.IsExtension() {
+			return !x.IsExtension() && y.IsExtension()
+		}
+		// Extensions sorted by fullname.
+		if x.IsExtension() && y.IsExtension() {
+			return x.FullName() < y.FullName()
+		}
+		// Non-extensions sorted by JSON name.
+		return fieldName(x, false) < fieldName(y, false)
+	}
+
+	// ProtoNameFieldOrder sorts non-extension fields before extension fields.
+	// Non-extensions are sorted according to their proto field name, or the
+	// (json_name_override) if it is set.
+	// Extensions are sorted according to their full name.
+	ProtoNameFieldOrder FieldOrder = func(x, y protoreflect.FieldDescriptor) bool {
+		// Non-extension fields sort before extension fields.
+		if x.IsExtension() != y.IsExtension() {
+			return !x.IsExtension() && y.IsExtension()
+		}
+		// Extensions sorted by fullname.
+		if x.IsExtension() && y.IsExtension() {
+			return x.FullName() < y.FullName()
+		}
+		// Non-extensions sorted by proto field name.
+		return fieldName(x, true) < fieldName(y, true)
+	}
+
 	// GenericKeyOrder sorts false before true, numeric keys in ascending order,
 	// and strings in lexicographical ordering according to UTF-8 codepoints.
 	GenericKeyOrder KeyOrder = func(x, y protoreflect.MapKey) bool {
@@ -82,6 +135,13 @@ var (
 			panic("invalid map key type")
 		}
 	}
+
+	// StringKeyOrder sorts keys by their string form in lexicographical
+	// ordering according to UTF-8 codepoints, which is the order the keys
+	// appear in as JSON object names.
+	StringKeyOrder KeyOrder = func(x, y protoreflect.MapKey) bool {
+		return x.String() < y.String()
+	}
 )
 
 // RangeFields iterates over the fields of fs according to the specified order.
@@ -151,3 +211,58 @@ func RangeEntries(es EntryRanger, less KeyOrder, fn VisitEntry) {
 		}
 	}
 }
+
+var (
+	// canonicalJSONNameFieldOrder sorts fields by their JSON name in UTF-16
+	// code units order, extension fields are named by "[full.name]".
+	canonicalJSONNameFieldOrder FieldOrder = func(x, y protoreflect.FieldDescriptor) bool {
+		return lessUTF16(canonicalFieldName(x, false), canonicalFieldName(y, false))
+	}
+
+	// canonicalProtoNameFieldOrder sorts fields by their proto name in UTF-16
+	// code units order, extension fields are named by "[full.name]".
+	canonicalProtoNameFieldOrder FieldOrder = func(x, y protoreflect.FieldDescriptor) bool {
+		return lessUTF16(canonicalFieldName(x, true), canonicalFieldName(y, true))
+	}
+
+	// canonicalKeyOrder sorts map keys by their string form in UTF-16 code
+	// units order.
+	canonicalKeyOrder KeyOrder = func(x, y protoreflect.MapKey) bool {
+		return lessUTF16(x.String(), y.String())
+	}
+)
+
+func canonicalFieldName(fd protoreflect.FieldDescriptor, useProtoNames bool) string {
+	if fd.IsExtension() {
+		return "[" + string(fd.FullName()) + "]"
+	}
+	return fieldName(fd, useProtoNames)
+}
+
+// lessUTF16 reports whether x sorts before y when both are compared as
+// sequences of UTF-16 code units, as required by RFC 8785.
+func lessUTF16(x, y string) bool {
+	for len(x) > 0 && len(y) > 0 {
+		rx, nx := utf8.DecodeRuneInString(x)
+		ry, ny := utf8.DecodeRuneInString(y)
+		if rx != ry {
+			ux1, ux2 := utf16Units(rx)
+			uy1, uy2 := utf16Units(ry)
+			if ux1 != uy1 {
+				return ux1 < uy1
+			}
+			return ux2 < uy2
+		}
+		x, y = x[nx:], y[ny:]
+	}
+	return len(x) < len(y)
+}
+
+// utf16Units returns the UTF-16 code units of r, the second one is zero if r
+// is encoded as a single unit.
+func utf16Units(r rune) (uint16, uint16) {
+	if r1, r2 := utf16.EncodeRune(r); r1 != unicode.ReplacementChar {
+		return uint16(r1), uint16(r2)
+	}
+	return uint16(r), 0
+}
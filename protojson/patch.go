@@ -0,0 +1,556 @@
+package protojson
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// The operations of a JSON Patch, see RFC 6902.
+const (
+	PatchAdd     = "add"
+	PatchRemove  = "remove"
+	PatchReplace = "replace"
+	PatchMove    = "move"
+	PatchCopy    = "copy"
+	PatchTest    = "test"
+)
+
+// Patch is a JSON Patch document as defined by RFC 6902, it is marshaled to
+// and unmarshaled from JSON with encoding/json.
+type Patch []PatchOperation
+
+// PatchOperation is an operation of a JSON Patch. Path and From are JSON
+// Pointers (RFC 6901) and Value is the JSON value of add, replace and test.
+type PatchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	From  string          `json:"from,omitempty"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// Diff returns the JSON Patch that turns the JSON of a into the JSON of b,
+// both marshaled with opts.
+func Diff(a, b proto.Message, opts MarshalOptions) (Patch, error) {
+	return opts.Diff(a, b)
+}
+
+// Diff returns the JSON Patch that turns the JSON of a into the JSON of b,
+// both marshaled using options in MarshalOptions, so the paths and values use
+// the same names and encoding as Marshal. Objects, including maps, are
+// compared by name, lists by index and nested messages recursively. A
+// google.protobuf.Any whose type changes is replaced as a whole. The messages
+// must be of the same type and not nil.
+func (o MarshalOptions) Diff(a, b proto.Message) (Patch, error) {
+	if a == nil || b == nil {
+		return nil, fmt.Errorf("proto: json patch: cannot diff a nil message")
+	}
+	if a.ProtoReflect().Descriptor().FullName() != b.ProtoReflect().Descriptor().FullName() {
+		return nil, fmt.Errorf("proto: json patch: cannot diff %s and %s",
+			a.ProtoReflect().Descriptor().FullName(), b.ProtoReflect().Descriptor().FullName())
+	}
+
+	o.Multiline = false
+	o.Indent = ""
+	x, err := o.marshalPatchNode(a)
+	if err != nil {
+		return nil, err
+	}
+	y, err := o.marshalPatchNode(b)
+	if err != nil {
+		return nil, err
+	}
+
+	patch := Patch{}
+	patch = diffPatchNode(patch, "", x, y)
+	return patch, nil
+}
+
+// Apply applies the JSON Patch to m, which is marshaled using default options.
+func Apply(m proto.Message, patch Patch) error {
+	return MarshalOptions{}.Apply(m, patch)
+}
+
+// Apply applies the JSON Patch to m. The patch is applied to the JSON of m
+// marshaled using options in MarshalOptions, which must name fields the way
+// the patch does, and the result is unmarshaled back into m. Redact and
+// FieldMask are ignored so that no data of m is lost, and the fields marked by
+// (json_omit) are kept. If any operation fails, m is left unchanged.
+func (o MarshalOptions) Apply(m proto.Message, patch Patch) error {
+	if m == nil || !m.ProtoReflect().IsValid() {
+		return fmt.Errorf("proto: json patch: cannot apply to a nil message")
+	}
+	o.Multiline = false
+	o.Indent = ""
+	o.Redact = nil
+	o.FieldMask = nil
+	doc, err := o.marshalPatchNode(m)
+	if err != nil {
+		return err
+	}
+
+	for i, op := range patch {
+		if doc, err = applyPatchOperation(doc, op); err != nil {
+			return fmt.Errorf("proto: json patch: operation %d (%s %q): %w", i, op.Op, op.Path, err)
+		}
+	}
+
+	pm := m.ProtoReflect()
+	n := pm.New().Interface()
+	if err := (UnmarshalOptions{Resolver: o.Resolver}).Unmarshal(doc.append(nil), n); err != nil {
+		return err
+	}
+
+	// keep the fields that are not part of the JSON
+	pm.Range(func(fd protoreflect.FieldDescriptor, _ protoreflect.Value) bool {
+		if !fieldOptionsOf(fd).omit {
+			pm.Clear(fd)
+		}
+		return true
+	})
+	proto.Merge(m, n)
+	return nil
+}
+
+// patchNode is a JSON value parsed for diffing and patching. Objects keep the
+// order of their names.
+type patchNode struct {
+	kind TokenKind
+	// raw is the JSON of the whole value.
+	raw []byte
+	// str is the parsed value of a string.
+	str string
+	// names and values are the members of an object.
+	names  []string
+	values []*patchNode
+	// elems are the elements of an array.
+	elems []*patchNode
+}
+
+func (o MarshalOptions) marshalPatchNode(m proto.Message) (*patchNode, error) {
+	b, err := o.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	return parsePatchNode(b)
+}
+
+// parsePatchNode parses the given JSON value.
+func parsePatchNode(b []byte) (*patchNode, error) {
+	d := NewDecoder(b)
+	n, err := readPatchNode(d, b)
+	if err != nil {
+		return nil, err
+	}
+	if tok, err := d.Read(); err != nil {
+		return nil, err
+	} else if tok.Kind() != TokenEOF {
+		return nil, fmt.Errorf("unexpected token %s", tok.RawString())
+	}
+	return n, nil
+}
+
+func readPatchNode(d *Decoder, b []byte) (*patchNode, error) {
+	tok, err := d.Read()
+	if err != nil {
+		return nil, err
+	}
+
+	n := &patchNode{kind: tok.Kind()}
+	start := tok.Pos()
+	switch tok.Kind() {
+	case TokenString:
+		n.str = tok.ParsedString()
+	case TokenObjectOpen:
+		for {
+			tok, err = d.Read()
+			if err != nil {
+				return nil, err
+			}
+			if tok.Kind() == TokenObjectClose {
+				break
+			}
+			v, err := readPatchNode(d, b)
+			if err != nil {
+				return nil, err
+			}
+			n.names = append(n.names, tok.Name())
+			n.values = append(n.values, v)
+		}
+	case TokenArrayOpen:
+		for {
+			if tok, err = d.Peek(); err != nil {
+				return nil, err
+			}
+			if tok.Kind() == TokenArrayClose {
+				_, _ = d.Read()
+				break
+			}
+			v, err := readPatchNode(d, b)
+			if err != nil {
+				return nil, err
+			}
+			n.elems = append(n.elems, v)
+		}
+	}
+	n.raw = b[start : tok.Pos()+len(tok.raw)]
+	return n, nil
+}
+
+// member returns the index of the object member with the given name, or -1.
+func (n *patchNode) member(name string) int {
+	for i, s := range n.names {
+		if s == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// append appends the JSON of the node to out.
+func (n *patchNode) append(out []byte) []byte {
+	switch n.kind {
+	case TokenObjectOpen:
+		out = append(out, '{')
+		for i, name := range n.names {
+			if i > 0 {
+				out = append(out, ',')
+			}
+			// names are valid UTF-8 as they come from parsed JSON
+			out, _ = appendString(out, name)
+			out = append(out, ':')
+			out = n.values[i].append(out)
+		}
+		return append(out, '}')
+	case TokenArrayOpen:
+		out = append(out, '[')
+		for i, v := range n.elems {
+			if i > 0 {
+				out = append(out, ',')
+			}
+			out = v.append(out)
+		}
+		return append(out, ']')
+	default:
+		return append(out, n.raw...)
+	}
+}
+
+// clone returns a deep copy of the node.
+func (n *patchNode) clone() *patchNode {
+	c := *n
+	c.names = append([]string(nil), n.names...)
+	c.values = make([]*patchNode, len(n.values))
+	for i, v := range n.values {
+		c.values[i] = v.clone()
+	}
+	c.elems = make([]*patchNode, len(n.elems))
+	for i, v := range n.elems {
+		c.elems[i] = v.clone()
+	}
+	return &c
+}
+
+// equal reports whether the nodes are the same JSON value, objects are
+// compared regardless of the order of their members.
+func (n *patchNode) equal(y *patchNode) bool {
+	if n.kind != y.kind {
+		return false
+	}
+	switch n.kind {
+	case TokenObjectOpen:
+		if len(n.names) != len(y.names) {
+			return false
+		}
+		for i, name := range n.names {
+			j := y.member(name)
+			if j < 0 || !n.values[i].equal(y.values[j]) {
+				return false
+			}
+		}
+		return true
+	case TokenArrayOpen:
+		if len(n.elems) != len(y.elems) {
+			return false
+		}
+		for i := range n.elems {
+			if !n.elems[i].equal(y.elems[i]) {
+				return false
+			}
+		}
+		return true
+	case TokenString:
+		return n.str == y.str
+	case TokenNumber:
+		a, errA := strconv.ParseFloat(string(n.raw), 64)
+		b, errB := strconv.ParseFloat(string(y.raw), 64)
+		return errA == nil && errB == nil && a == b
+	default:
+		return bytes.Equal(n.raw, y.raw)
+	}
+}
+
+// diffPatchNode appends to patch the operations that turn x into y at path.
+func diffPatchNode(patch Patch, path string, x, y *patchNode) Patch {
+	if bytes.Equal(x.raw, y.raw) {
+		return patch
+	}
+
+	switch {
+	case x.kind != y.kind:
+	case x.kind == TokenObjectOpen:
+		if i, j := x.member(anyTypeFieldName), y.member(anyTypeFieldName); i >= 0 && j >= 0 &&
+			!bytes.Equal(x.values[i].raw, y.values[j].raw) {
+			// an Any of another type
+			break
+		}
+		for _, name := range x.names {
+			if y.member(name) < 0 {
+				patch = append(patch, PatchOperation{Op: PatchRemove, Path: path + "/" + escapePointer(name)})
+			}
+		}
+		for j, name := range y.names {
+			p := path + "/" + escapePointer(name)
+			if i := x.member(name); i < 0 {
+				patch = append(patch, PatchOperation{Op: PatchAdd, Path: p, Value: y.values[j].raw})
+			} else {
+				patch = diffPatchNode(patch, p, x.values[i], y.values[j])
+			}
+		}
+		return patch
+	case x.kind == TokenArrayOpen:
+		n := len(x.elems)
+		if len(y.elems) < n {
+			n = len(y.elems)
+		}
+		for i := 0; i < n; i++ {
+			patch = diffPatchNode(patch, path+"/"+strconv.Itoa(i), x.elems[i], y.elems[i])
+		}
+		for i := len(x.elems) - 1; i >= n; i-- {
+			patch = append(patch, PatchOperation{Op: PatchRemove, Path: path + "/" + strconv.Itoa(i)})
+		}
+		for i := n; i < len(y.elems); i++ {
+			patch = append(patch, PatchOperation{Op: PatchAdd, Path: path + "/" + strconv.Itoa(i), Value: y.elems[i].raw})
+		}
+		return patch
+	}
+	return append(patch, PatchOperation{Op: PatchReplace, Path: path, Value: y.raw})
+}
+
+// escapePointer escapes a reference token of a JSON Pointer.
+func escapePointer(s string) string {
+	if strings.IndexAny(s, "~/") < 0 {
+		return s
+	}
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(s)
+}
+
+// splitPointer splits a JSON Pointer into its unescaped reference tokens.
+func splitPointer(path string) ([]string, error) {
+	if path == "" {
+		return nil, nil
+	}
+	if path[0] != '/' {
+		return nil, fmt.Errorf("invalid JSON pointer %q", path)
+	}
+	tokens := strings.Split(path[1:], "/")
+	for i, s := range tokens {
+		if strings.IndexByte(s, '~') >= 0 {
+			tokens[i] = strings.NewReplacer("~1", "/", "~0", "~").Replace(s)
+		}
+	}
+	return tokens, nil
+}
+
+// applyPatchOperation applies the operation to doc and returns the new doc.
+func applyPatchOperation(doc *patchNode, op PatchOperation) (*patchNode, error) {
+	var value *patchNode
+	switch op.Op {
+	case PatchAdd, PatchReplace, PatchTest:
+		if len(op.Value) == 0 {
+			return nil, fmt.Errorf("missing value")
+		}
+		v, err := parsePatchNode(op.Value)
+		if err != nil {
+			return nil, err
+		}
+		value = v
+	case PatchMove, PatchCopy:
+		v, err := lookupPatchNode(doc, op.From)
+		if err != nil {
+			return nil, err
+		}
+		value = v.clone()
+	}
+
+	switch op.Op {
+	case PatchAdd, PatchCopy:
+		return addPatchNode(doc, op.Path, value)
+	case PatchRemove:
+		return removePatchNode(doc, op.Path)
+	case PatchReplace:
+		if op.Path == "" {
+			return value, nil
+		}
+		doc, err := removePatchNode(doc, op.Path)
+		if err != nil {
+			return nil, err
+		}
+		return addPatchNode(doc, op.Path, value)
+	case PatchMove:
+		if op.Path != op.From && strings.HasPrefix(op.Path, op.From+"/") {
+			return nil, fmt.Errorf("cannot move %q into itself", op.From)
+		}
+		doc, err := removePatchNode(doc, op.From)
+		if err != nil {
+			return nil, err
+		}
+		return addPatchNode(doc, op.Path, value)
+	case PatchTest:
+		v, err := lookupPatchNode(doc, op.Path)
+		if err != nil {
+			return nil, err
+		}
+		if !v.equal(value) {
+			return nil, fmt.Errorf("test failed")
+		}
+		return doc, nil
+	default:
+		return nil, fmt.Errorf("unknown operation %q", op.Op)
+	}
+}
+
+// lookupPatchNode returns the node referenced by the JSON Pointer.
+func lookupPatchNode(doc *patchNode, path string) (*patchNode, error) {
+	tokens, err := splitPointer(path)
+	if err != nil {
+		return nil, err
+	}
+	n := doc
+	for _, token := range tokens {
+		if n, err = n.child(token); err != nil {
+			return nil, err
+		}
+	}
+	return n, nil
+}
+
+// parentPatchNode returns the parent of the node referenced by the JSON
+// Pointer and the last reference token.
+func parentPatchNode(doc *patchNode, path string) (*patchNode, string, error) {
+	tokens, err := splitPointer(path)
+	if err != nil {
+		return nil, "", err
+	}
+	n := doc
+	for _, token := range tokens[:len(tokens)-1] {
+		if n, err = n.child(token); err != nil {
+			return nil, "", err
+		}
+	}
+	return n, tokens[len(tokens)-1], nil
+}
+
+// child returns the member or the element referenced by token.
+func (n *patchNode) child(token string) (*patchNode, error) {
+	switch n.kind {
+	case TokenObjectOpen:
+		if i := n.member(token); i >= 0 {
+			return n.values[i], nil
+		}
+		return nil, fmt.Errorf("member %q not found", token)
+	case TokenArrayOpen:
+		i, err := n.index(token, false)
+		if err != nil {
+			return nil, err
+		}
+		return n.elems[i], nil
+	default:
+		return nil, fmt.Errorf("cannot reference %q in a scalar value", token)
+	}
+}
+
+// index parses the array index token. If add, the index may be one past the
+// last element, which "-" also refers to.
+func (n *patchNode) index(token string, add bool) (int, error) {
+	max := len(n.elems) - 1
+	if add {
+		max++
+		if token == "-" {
+			return max, nil
+		}
+	}
+	i, err := strconv.Atoi(token)
+	if err != nil || i < 0 || i > max || (len(token) > 1 && token[0] == '0') {
+		return 0, fmt.Errorf("invalid array index %q", token)
+	}
+	return i, nil
+}
+
+// addPatchNode adds the value at the JSON Pointer and returns the new doc.
+func addPatchNode(doc *patchNode, path string, value *patchNode) (*patchNode, error) {
+	if path == "" {
+		return value, nil
+	}
+	parent, token, err := parentPatchNode(doc, path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch parent.kind {
+	case TokenObjectOpen:
+		if i := parent.member(token); i >= 0 {
+			parent.values[i] = value
+		} else {
+			parent.names = append(parent.names, token)
+			parent.values = append(parent.values, value)
+		}
+	case TokenArrayOpen:
+		i, err := parent.index(token, true)
+		if err != nil {
+			return nil, err
+		}
+		parent.elems = append(parent.elems, nil)
+		copy(parent.elems[i+1:], parent.elems[i:])
+		parent.elems[i] = value
+	default:
+		return nil, fmt.Errorf("cannot add %q to a scalar value", token)
+	}
+	return doc, nil
+}
+
+// removePatchNode removes the value at the JSON Pointer and returns the new
+// doc.
+func removePatchNode(doc *patchNode, path string) (*patchNode, error) {
+	if path == "" {
+		return nil, fmt.Errorf("cannot remove the whole document")
+	}
+	parent, token, err := parentPatchNode(doc, path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch parent.kind {
+	case TokenObjectOpen:
+		i := parent.member(token)
+		if i < 0 {
+			return nil, fmt.Errorf("member %q not found", token)
+		}
+		parent.names = append(parent.names[:i], parent.names[i+1:]...)
+		parent.values = append(parent.values[:i], parent.values[i+1:]...)
+	case TokenArrayOpen:
+		i, err := parent.index(token, false)
+		if err != nil {
+			return nil, err
+		}
+		parent.elems = append(parent.elems[:i], parent.elems[i+1:]...)
+	default:
+		return nil, fmt.Errorf("cannot remove %q from a scalar value", token)
+	}
+	return doc, nil
+}
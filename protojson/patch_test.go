@@ -0,0 +1,186 @@
+package protojson
+
+import (
+	"encoding/json"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestDiff(t *testing.T) {
+	person, err := anypb.New(&Person{Name: "bob", Age: 18})
+	if err != nil {
+		t.Fatal(err)
+	}
+	older, err := anypb.New(&Person{Name: "bob", Age: 19, Like: "book"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	duration, err := anypb.New(durationpb.New(time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a := &HelloRequest{
+		Success: true,
+		Age:     18,
+		Tags:    []string{"a", "b", "c"},
+		Labels:  map[string]string{"x": "1", "a/b~c": "2"},
+		Any:     person,
+	}
+
+	tests := []struct {
+		name   string
+		opts   MarshalOptions
+		b      proto.Message
+		expect string
+	}{
+		{"equal", MarshalOptions{}, proto.Clone(a), `[]`},
+		{"scalars", MarshalOptions{}, &HelloRequest{Age: 20, Timestamp: 3, Tags: a.Tags, Labels: a.Labels, Any: person},
+			`[{"op":"remove","path":"/success"},{"op":"replace","path":"/age","value":20},{"op":"add","path":"/timestamp","value":"3"}]`},
+		{"proto names", MarshalOptions{UseProtoNames: true, EmitUnpopulated: true, Int64AsNumber: true}, &HelloRequest{Success: true, Age: 18, Timestamp: 3, Tags: a.Tags, Labels: a.Labels, Any: person},
+			`[{"op":"replace","path":"/timestamp","value":3}]`},
+		{"list", MarshalOptions{}, &HelloRequest{Success: true, Age: 18, Tags: []string{"a", "d"}, Labels: a.Labels, Any: person},
+			`[{"op":"replace","path":"/tags/1","value":"d"},{"op":"remove","path":"/tags/2"}]`},
+		{"list grow", MarshalOptions{}, &HelloRequest{Success: true, Age: 18, Tags: []string{"a", "b", "c", "d", "e"}, Labels: a.Labels, Any: person},
+			`[{"op":"add","path":"/tags/3","value":"d"},{"op":"add","path":"/tags/4","value":"e"}]`},
+		{"map", MarshalOptions{}, &HelloRequest{Success: true, Age: 18, Tags: a.Tags, Labels: map[string]string{"a/b~c": "3", "y": "1"}, Any: person},
+			`[{"op":"remove","path":"/labels/x"},{"op":"replace","path":"/labels/a~1b~0c","value":"3"},{"op":"add","path":"/labels/y","value":"1"}]`},
+		{"any", MarshalOptions{}, &HelloRequest{Success: true, Age: 18, Tags: a.Tags, Labels: a.Labels, Any: older},
+			`[{"op":"add","path":"/any/like","value":"book"},{"op":"replace","path":"/any/age","value":19}]`},
+		{"any type", MarshalOptions{}, &HelloRequest{Success: true, Age: 18, Tags: a.Tags, Labels: a.Labels, Any: duration},
+			`[{"op":"replace","path":"/any","value":{"@type":"type.googleapis.com/google.protobuf.Duration","value":"1s"}}]`},
+	}
+	for _, test := range tests {
+		patch, err := Diff(a, test.b, test.opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := json.Marshal(patch)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != test.expect {
+			t.Errorf("%s: expect %s, but got %s", test.name, test.expect, b)
+		}
+
+		m := proto.Clone(a)
+		if err := test.opts.Apply(m, patch); err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if !proto.Equal(m, test.b) {
+			t.Errorf("%s: expect %v, but got %v", test.name, test.b, m)
+		}
+	}
+
+	if _, err := Diff(a, &Person{}, MarshalOptions{}); err == nil {
+		t.Errorf("expect error for messages of different types")
+	}
+	if _, err := Diff(nil, a, MarshalOptions{}); err == nil {
+		t.Errorf("expect error for a nil message")
+	}
+	if _, err := Diff(a, nil, MarshalOptions{}); err == nil {
+		t.Errorf("expect error for a nil message")
+	}
+	if err := Apply(nil, Patch{}); err == nil {
+		t.Errorf("expect error for a nil message")
+	}
+	if err := Apply((*HelloRequest)(nil), Patch{}); err == nil {
+		t.Errorf("expect error for a nil message")
+	}
+	patch, err := Diff(timestamppb.New(timestamppb.Now().AsTime()), &timestamppb.Timestamp{Seconds: 1}, MarshalOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(patch) != 1 || patch[0].Path != "" || string(patch[0].Value) != `"1970-01-01T00:00:01Z"` {
+		t.Errorf("unexpected patch %v", patch)
+	}
+}
+
+func TestApply(t *testing.T) {
+	a := &HelloRequest{
+		Age:    18,
+		Tags:   []string{"a", "b"},
+		Labels: map[string]string{"x": "1"},
+	}
+
+	var patch Patch
+	err := json.Unmarshal([]byte(`[
+		{"op":"test","path":"/age","value":18.0},
+		{"op":"copy","from":"/tags/0","path":"/tags/-"},
+		{"op":"move","from":"/labels/x","path":"/labels/y"},
+		{"op":"add","path":"/tags/0","value":"z"},
+		{"op":"add","path":"/score","value":"NaN"},
+		{"op":"replace","path":"/timestamp","value":7},
+		{"op":"test","path":"/labels","value":{"y":"1"}}
+	]`), &patch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Apply(a, patch); err == nil || !strings.Contains(err.Error(), `operation 5 (replace "/timestamp")`) {
+		t.Errorf("expect replace error, but got %v", err)
+	}
+	if a.Age != 18 || len(a.Tags) != 2 {
+		t.Errorf("expect message unchanged, but got %v", a)
+	}
+
+	patch[5].Op = PatchAdd
+	if err := Apply(a, patch); err != nil {
+		t.Fatal(err)
+	}
+	if !math.IsNaN(float64(a.Score)) {
+		t.Errorf("expect NaN, but got %v", a.Score)
+	}
+	a.Score = 0
+	expect := &HelloRequest{
+		Age:       18,
+		Timestamp: 7,
+		Tags:      []string{"z", "a", "b", "a"},
+		Labels:    map[string]string{"y": "1"},
+	}
+	if !proto.Equal(a, expect) {
+		t.Errorf("expect %v, but got %v", expect, a)
+	}
+
+	errTests := []struct {
+		op     PatchOperation
+		expect string
+	}{
+		{PatchOperation{Op: PatchTest, Path: "/age", Value: json.RawMessage(`19`)}, "test failed"},
+		{PatchOperation{Op: PatchRemove, Path: "/tags/9"}, `invalid array index "9"`},
+		{PatchOperation{Op: PatchRemove, Path: "/tags/01"}, `invalid array index "01"`},
+		{PatchOperation{Op: PatchAdd, Path: "/age/x", Value: json.RawMessage(`1`)}, `cannot add "x" to a scalar value`},
+		{PatchOperation{Op: PatchAdd, Path: "/age"}, "missing value"},
+		{PatchOperation{Op: PatchMove, From: "/labels", Path: "/labels/z"}, "into itself"},
+		{PatchOperation{Op: "merge", Path: "/age"}, `unknown operation "merge"`},
+		{PatchOperation{Op: PatchRemove, Path: "age"}, "invalid JSON pointer"},
+		{PatchOperation{Op: PatchAdd, Path: "/unknown", Value: json.RawMessage(`1`)}, `unknown field "unknown"`},
+	}
+	for _, test := range errTests {
+		if err := Apply(proto.Clone(a), Patch{test.op}); err == nil || !strings.Contains(err.Error(), test.expect) {
+			t.Errorf("%v: expect error %q, but got %v", test.op, test.expect, err)
+		}
+	}
+}
+
+func TestApplyKeepsOmittedFields(t *testing.T) {
+	md := newLegacyMessage(t)
+	m := newLegacy(md)
+	err := Apply(m, Patch{{Op: PatchReplace, Path: "/UserName", Value: json.RawMessage(`"alice"`)}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fds := md.Fields()
+	if got := m.Get(fds.ByName("user_name")).String(); got != "alice" {
+		t.Errorf("expect alice, but got %s", got)
+	}
+	if got := m.Get(fds.ByName("password")).String(); got != "123456" {
+		t.Errorf("expect 123456, but got %s", got)
+	}
+}
@@ -0,0 +1,189 @@
+package protojson
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"unicode/utf8"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+const defaultMask = "***"
+
+// RedactMode is the way a sensitive field is masked.
+type RedactMode uint8
+
+const (
+	// RedactMask replaces the value with a fixed mask. Strings become "***",
+	// bytes become empty, other scalars become their default value and
+	// messages become empty messages, so the JSON type of the field is kept.
+	// A google.protobuf.Value cannot be empty and becomes null instead.
+	RedactMask RedactMode = iota
+	// RedactKeepLast masks strings but keeps their last KeepLast characters.
+	// Other kinds are handled as RedactMask.
+	RedactKeepLast
+	// RedactHash replaces strings with the hex encoded SHA-256 of the value and
+	// bytes with the raw SHA-256 digest. Other kinds are handled as RedactMask.
+	RedactHash
+	// RedactDrop omits the field from the output.
+	RedactDrop
+)
+
+// RedactStrategy describes how a sensitive field is masked.
+type RedactStrategy struct {
+	Mode RedactMode
+	// KeepLast is the number of trailing characters left unmasked by
+	// RedactKeepLast. Strings not longer than KeepLast are fully masked.
+	KeepLast int
+}
+
+// Redaction masks the fields marked as sensitive by a field option, e.g.
+//
+//	string name = 1 [(sens) = true];
+//
+// Every message visited by the encoder is checked, including nested messages,
+// list elements, map values and the payload of google.protobuf.Any. When a
+// list or map field is sensitive, each element is masked while the list or
+// map itself is kept.
+type Redaction struct {
+	// Extension is the field option that marks a field as sensitive. A bool
+	// option must be set to true, any other option only needs to be present.
+	// If nil, E_Sens is used.
+	Extension protoreflect.ExtensionType
+
+	// Strategy returns how to mask the given sensitive field.
+	// If nil, all sensitive fields are masked with RedactMask.
+	Strategy func(fd protoreflect.FieldDescriptor) RedactStrategy
+}
+
+// Sensitive reports whether the given field is marked as sensitive.
+func (r *Redaction) Sensitive(fd protoreflect.FieldDescriptor) bool {
+	opts := fd.Options()
+	if opts == nil {
+		return false
+	}
+
+	xt := r.Extension
+	if xt == nil {
+		xt = E_Sens
+	}
+	if !proto.HasExtension(opts, xt) {
+		return false
+	}
+	if b, ok := proto.GetExtension(opts, xt).(bool); ok {
+		return b
+	}
+	return true
+}
+
+// Ranger wraps the given FieldRanger so that sensitive fields are masked.
+func (r *Redaction) Ranger(fields FieldRanger) FieldRanger {
+	return redactRanger{fields: fields, r: r}
+}
+
+func (r *Redaction) strategy(fd protoreflect.FieldDescriptor) RedactStrategy {
+	if r.Strategy == nil {
+		return RedactStrategy{Mode: RedactMask}
+	}
+	return r.Strategy(fd)
+}
+
+type redactRanger struct {
+	fields FieldRanger
+	r      *Redaction
+}
+
+func (o redactRanger) Range(f VisitField) {
+	o.fields.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		// invalid value emits null, nothing to mask
+		if !v.IsValid() || !o.r.Sensitive(fd) {
+			return f(fd, v)
+		}
+
+		s := o.r.strategy(fd)
+		switch {
+		case s.Mode == RedactDrop:
+			return true
+		case fd.IsList():
+			v = protoreflect.ValueOfList(redactedList{List: v.List(), fd: fd, s: s})
+		case fd.IsMap():
+			v = protoreflect.ValueOfMap(redactedMap{Map: v.Map(), fd: fd.MapValue(), s: s})
+		default:
+			v = redactValue(fd, v, s)
+		}
+		return f(fd, v)
+	})
+}
+
+// redactedList is a read-only view of a list whose elements are masked.
+type redactedList struct {
+	protoreflect.List
+	fd protoreflect.FieldDescriptor
+	s  RedactStrategy
+}
+
+func (l redactedList) Get(i int) protoreflect.Value {
+	return redactValue(l.fd, l.List.Get(i), l.s)
+}
+
+// redactedMap is a read-only view of a map whose values are masked.
+type redactedMap struct {
+	protoreflect.Map
+	fd protoreflect.FieldDescriptor
+	s  RedactStrategy
+}
+
+func (m redactedMap) Get(k protoreflect.MapKey) protoreflect.Value {
+	return redactValue(m.fd, m.Map.Get(k), m.s)
+}
+
+func (m redactedMap) Range(f VisitEntry) {
+	m.Map.Range(func(k protoreflect.MapKey, v protoreflect.Value) bool {
+		return f(k, redactValue(m.fd, v, m.s))
+	})
+}
+
+// redactValue masks a singular value of the given field.
+func redactValue(fd protoreflect.FieldDescriptor, v protoreflect.Value, s RedactStrategy) protoreflect.Value {
+	switch fd.Kind() {
+	case protoreflect.StringKind:
+		str := v.String()
+		switch s.Mode {
+		case RedactKeepLast:
+			n := utf8.RuneCountInString(str)
+			if s.KeepLast <= 0 || n <= s.KeepLast {
+				return protoreflect.ValueOfString(defaultMask)
+			}
+			i := len(str)
+			for k := 0; k < s.KeepLast; k++ {
+				_, size := utf8.DecodeLastRuneInString(str[:i])
+				i -= size
+			}
+			return protoreflect.ValueOfString(defaultMask + str[i:])
+		case RedactHash:
+			sum := sha256.Sum256([]byte(str))
+			return protoreflect.ValueOfString(hex.EncodeToString(sum[:]))
+		default:
+			return protoreflect.ValueOfString(defaultMask)
+		}
+
+	case protoreflect.BytesKind:
+		if s.Mode == RedactHash {
+			sum := sha256.Sum256(v.Bytes())
+			return protoreflect.ValueOfBytes(sum[:])
+		}
+		return protoreflect.ValueOfBytes(nil)
+
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		m := v.Message().Type().New()
+		if md := m.Descriptor(); md.FullName() == Value_message_fullname {
+			// an empty Value fails to marshal, null is the closest empty form
+			m.Set(md.Fields().ByName("null_value"), protoreflect.ValueOfEnum(0))
+		}
+		return protoreflect.ValueOfMessage(m)
+
+	default:
+		return fd.Default()
+	}
+}
@@ -0,0 +1,216 @@
+package protojson
+
+import (
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// newRedactMessage builds the following message at runtime:
+//
+//	message Account {
+//	  string name = 1;
+//	  string phone = 2 [(sens) = true];
+//	  int32 age = 3 [(sens) = true];
+//	  repeated string cards = 4 [(sens) = true];
+//	  map<string, int64> balances = 5 [(sens) = true];
+//	  Account parent = 6;
+//	  bytes secret = 7 [(sens) = true];
+//	  google.protobuf.Any extra = 8;
+//	}
+func newRedactMessage(t *testing.T) protoreflect.MessageDescriptor {
+	sens := func() *descriptorpb.FieldOptions {
+		opts := &descriptorpb.FieldOptions{}
+		proto.SetExtension(opts, E_Sens, true)
+		return opts
+	}
+	field := func(name string, num int32, typ descriptorpb.FieldDescriptorProto_Type, opts *descriptorpb.FieldOptions) *descriptorpb.FieldDescriptorProto {
+		return &descriptorpb.FieldDescriptorProto{
+			Name:     proto.String(name),
+			JsonName: proto.String(jsonCamelCase(name)),
+			Number:   proto.Int32(num),
+			Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+			Type:     typ.Enum(),
+			Options:  opts,
+		}
+	}
+
+	cards := field("cards", 4, descriptorpb.FieldDescriptorProto_TYPE_STRING, sens())
+	cards.Label = descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum()
+	balances := field("balances", 5, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE, sens())
+	balances.Label = descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum()
+	balances.TypeName = proto.String(".redact.Account.BalancesEntry")
+	parent := field("parent", 6, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE, nil)
+	parent.TypeName = proto.String(".redact.Account")
+	extra := field("extra", 8, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE, nil)
+	extra.TypeName = proto.String(".google.protobuf.Any")
+
+	fdp := &descriptorpb.FileDescriptorProto{
+		Name:       proto.String("redact_test.proto"),
+		Package:    proto.String("redact"),
+		Syntax:     proto.String("proto3"),
+		Dependency: []string{"ext.proto", "google/protobuf/any.proto"},
+		MessageType: []*descriptorpb.DescriptorProto{{
+			Name: proto.String("Account"),
+			Field: []*descriptorpb.FieldDescriptorProto{
+				field("name", 1, descriptorpb.FieldDescriptorProto_TYPE_STRING, nil),
+				field("phone", 2, descriptorpb.FieldDescriptorProto_TYPE_STRING, sens()),
+				field("age", 3, descriptorpb.FieldDescriptorProto_TYPE_INT32, sens()),
+				cards,
+				balances,
+				parent,
+				field("secret", 7, descriptorpb.FieldDescriptorProto_TYPE_BYTES, sens()),
+				extra,
+			},
+			NestedType: []*descriptorpb.DescriptorProto{{
+				Name: proto.String("BalancesEntry"),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					field("key", 1, descriptorpb.FieldDescriptorProto_TYPE_STRING, nil),
+					field("value", 2, descriptorpb.FieldDescriptorProto_TYPE_INT64, nil),
+				},
+				Options: &descriptorpb.MessageOptions{MapEntry: proto.Bool(true)},
+			}},
+		}},
+	}
+
+	fd, err := protodesc.NewFile(fdp, protoregistry.GlobalFiles)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fd.Messages().Get(0)
+}
+
+func TestMarshalRedact(t *testing.T) {
+	md := newRedactMessage(t)
+	fds := md.Fields()
+
+	a, err := anypb.New(&Person{Name: "bob", Like: "book", Age: 18})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	newAccount := func() *dynamicpb.Message {
+		m := dynamicpb.NewMessage(md)
+		m.Set(fds.ByName("name"), protoreflect.ValueOfString("bob"))
+		m.Set(fds.ByName("phone"), protoreflect.ValueOfString("13800001234"))
+		m.Set(fds.ByName("age"), protoreflect.ValueOfInt32(18))
+		cards := m.Mutable(fds.ByName("cards")).List()
+		cards.Append(protoreflect.ValueOfString("6222020000001111"))
+		balances := m.Mutable(fds.ByName("balances")).Map()
+		balances.Set(protoreflect.ValueOfString("cny").MapKey(), protoreflect.ValueOfInt64(100))
+		m.Set(fds.ByName("secret"), protoreflect.ValueOfBytes([]byte("pwd")))
+		return m
+	}
+	m := newAccount()
+	m.Set(fds.ByName("parent"), protoreflect.ValueOfMessage(newAccount()))
+	m.Set(fds.ByName("extra"), protoreflect.ValueOfMessage(a.ProtoReflect()))
+
+	tests := []struct {
+		name     string
+		strategy func(fd protoreflect.FieldDescriptor) RedactStrategy
+		expect   string
+	}{
+		{
+			"mask",
+			nil,
+			`{"name":"bob","phone":"***","age":0,"cards":["***"],"balances":{"cny":"0"},` +
+				`"parent":{"name":"bob","phone":"***","age":0,"cards":["***"],"balances":{"cny":"0"},"secret":""},` +
+				`"secret":"","extra":{"@type":"type.googleapis.com/Person","name":"***","like":"book","age":18}}`,
+		},
+		{
+			"keep last",
+			func(fd protoreflect.FieldDescriptor) RedactStrategy {
+				return RedactStrategy{Mode: RedactKeepLast, KeepLast: 4}
+			},
+			`{"name":"bob","phone":"***1234","age":0,"cards":["***1111"],"balances":{"cny":"0"},` +
+				`"parent":{"name":"bob","phone":"***1234","age":0,"cards":["***1111"],"balances":{"cny":"0"},"secret":""},` +
+				`"secret":"","extra":{"@type":"type.googleapis.com/Person","name":"***","like":"book","age":18}}`,
+		},
+		{
+			"drop",
+			func(fd protoreflect.FieldDescriptor) RedactStrategy {
+				return RedactStrategy{Mode: RedactDrop}
+			},
+			`{"name":"bob","parent":{"name":"bob"},"extra":{"@type":"type.googleapis.com/Person","like":"book","age":18}}`,
+		},
+		{
+			"hash",
+			func(fd protoreflect.FieldDescriptor) RedactStrategy {
+				if fd.Name() == "phone" {
+					return RedactStrategy{Mode: RedactHash}
+				}
+				return RedactStrategy{Mode: RedactDrop}
+			},
+			`{"name":"bob","phone":"3941f6ec03babc666839f3e70c85469510368782e9a673132fde1bb8fa6f5aed",` +
+				`"parent":{"name":"bob","phone":"3941f6ec03babc666839f3e70c85469510368782e9a673132fde1bb8fa6f5aed"},` +
+				`"extra":{"@type":"type.googleapis.com/Person","like":"book","age":18}}`,
+		},
+	}
+
+	for _, test := range tests {
+		b, err := MarshalOptions{Redact: &Redaction{Strategy: test.strategy}}.Marshal(m)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if string(b) != test.expect {
+			t.Errorf("%s: expect %s, but got %s", test.name, test.expect, b)
+		}
+	}
+}
+
+func TestMarshalRedactValue(t *testing.T) {
+	sens := &descriptorpb.FieldOptions{}
+	proto.SetExtension(sens, E_Sens, true)
+	fdp := &descriptorpb.FileDescriptorProto{
+		Name:       proto.String("redact_value_test.proto"),
+		Package:    proto.String("redact"),
+		Syntax:     proto.String("proto3"),
+		Dependency: []string{"ext.proto", "google/protobuf/struct.proto"},
+		MessageType: []*descriptorpb.DescriptorProto{{
+			Name: proto.String("Event"),
+			Field: []*descriptorpb.FieldDescriptorProto{{
+				Name:     proto.String("payload"),
+				JsonName: proto.String("payload"),
+				Number:   proto.Int32(1),
+				Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+				Type:     descriptorpb.FieldDescriptorProto_TYPE_MESSAGE.Enum(),
+				TypeName: proto.String(".google.protobuf.Value"),
+				Options:  sens,
+			}, {
+				Name:     proto.String("history"),
+				JsonName: proto.String("history"),
+				Number:   proto.Int32(2),
+				Label:    descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum(),
+				Type:     descriptorpb.FieldDescriptorProto_TYPE_MESSAGE.Enum(),
+				TypeName: proto.String(".google.protobuf.Value"),
+				Options:  sens,
+			}},
+		}},
+	}
+	fd, err := protodesc.NewFile(fdp, protoregistry.GlobalFiles)
+	if err != nil {
+		t.Fatal(err)
+	}
+	md := fd.Messages().Get(0)
+
+	m := dynamicpb.NewMessage(md)
+	m.Set(md.Fields().ByName("payload"), protoreflect.ValueOfMessage(structpb.NewStringValue("secret").ProtoReflect()))
+	history := m.Mutable(md.Fields().ByName("history")).List()
+	history.Append(protoreflect.ValueOfMessage(structpb.NewNumberValue(1).ProtoReflect()))
+
+	b, err := MarshalOptions{Redact: &Redaction{}}.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := `{"payload":null,"history":[null]}`
+	if string(b) != expect {
+		t.Errorf("expect %s, but got %s", expect, b)
+	}
+}
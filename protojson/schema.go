@@ -0,0 +1,547 @@
+package protojson
+
+import (
+	"sort"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+const (
+	// schemaDialect is the JSON Schema dialect of the generated schemas.
+	schemaDialect = "https://json-schema.org/draft/2020-12/schema"
+	// schemaDefsPrefix is the JSON pointer prefix of the schema definitions.
+	schemaDefsPrefix = "#/$defs/"
+)
+
+// Schema returns the JSON Schema (draft 2020-12) of the JSON produced by
+// marshaling messages of the given descriptor with opts. It follows the
+// encoder: field names honour UseProtoNames and (json_name_override), fields
+// marked by (json_omit) are left out, 64-bit integers are strings unless
+// Int64AsNumber or (json_int64_as_number) applies, enums are names unless
+// UseEnumNumbers is set, and well-known types have their special JSON form.
+//
+// Every message and enum is a definition in "$defs" named by its full name and
+// referenced by "$ref", which also describes recursive messages. The root
+// schema references the definition of desc. Properties are never required
+// because unpopulated fields may be omitted. Multiline and Indent format the
+// schema itself. Canonical emits it as canonical JSON: no insignificant
+// whitespace and every object name, schema keywords included, sorted by its
+// UTF-16 code units.
+func Schema(desc protoreflect.MessageDescriptor, opts MarshalOptions) ([]byte, error) {
+	opts = opts.withDefaults()
+
+	internalEnc := newEncoder(opts.Indent)
+	defer encoderPool.Put(internalEnc)
+
+	defs := make(map[protoreflect.FullName]protoreflect.Descriptor)
+	collectSchemaDefs(desc, defs)
+	names := make([]string, 0, len(defs))
+	for name := range defs {
+		names = append(names, string(name))
+	}
+	sort.Strings(names)
+
+	// definitions of well-known types follow the message level option only
+	e := encoder{Encoder: internalEnc, opts: opts, int64AsNumber: opts.Int64AsNumber}
+	e.StartObject()
+	e.writeSchemaString("$schema", schemaDialect)
+	e.writeSchemaString("$ref", schemaDefsPrefix+string(desc.FullName()))
+	e.WriteName("$defs")
+	e.StartObject()
+	for _, name := range names {
+		if err := e.WriteName(name); err != nil {
+			return nil, err
+		}
+		switch d := defs[protoreflect.FullName(name)].(type) {
+		case protoreflect.MessageDescriptor:
+			if err := e.schemaMessage(d); err != nil {
+				return nil, err
+			}
+		case protoreflect.EnumDescriptor:
+			e.schemaEnum(d)
+		}
+	}
+	e.EndObject()
+	e.EndObject()
+
+	buf := append([]byte(nil), internalEnc.Bytes()...)
+	if opts.Canonical {
+		return canonicalSchema(buf)
+	}
+	return buf, nil
+}
+
+// canonicalSchema re-emits the generated schema with object names sorted by
+// their UTF-16 code units, as the keywords are written in a fixed order.
+func canonicalSchema(b []byte) ([]byte, error) {
+	node, err := readSchemaNode(NewDecoder(b))
+	if err != nil {
+		return nil, err
+	}
+
+	internalEnc := newEncoder("")
+	defer encoderPool.Put(internalEnc)
+	if err := node.write(internalEnc); err != nil {
+		return nil, err
+	}
+	buf := append([]byte(nil), internalEnc.Bytes()...)
+	return buf, nil
+}
+
+// schemaNode is a parsed JSON value of a generated schema. Object members are
+// kept sorted by name.
+type schemaNode struct {
+	tok     Token
+	members []schemaMember
+	elems   []*schemaNode
+}
+
+type schemaMember struct {
+	name  string
+	value *schemaNode
+}
+
+func readSchemaNode(d *Decoder) (*schemaNode, error) {
+	tok, err := d.Read()
+	if err != nil {
+		return nil, err
+	}
+
+	n := &schemaNode{tok: tok}
+	switch tok.Kind() {
+	case TokenObjectOpen:
+		for {
+			tok, err := d.Read()
+			if err != nil {
+				return nil, err
+			}
+			if tok.Kind() == TokenObjectClose {
+				break
+			}
+			value, err := readSchemaNode(d)
+			if err != nil {
+				return nil, err
+			}
+			n.members = append(n.members, schemaMember{name: tok.Name(), value: value})
+		}
+		sort.Slice(n.members, func(i, j int) bool {
+			return lessUTF16(n.members[i].name, n.members[j].name)
+		})
+
+	case TokenArrayOpen:
+		for {
+			tok, err := d.Peek()
+			if err != nil {
+				return nil, err
+			}
+			if tok.Kind() == TokenArrayClose {
+				_, _ = d.Read()
+				break
+			}
+			elem, err := readSchemaNode(d)
+			if err != nil {
+				return nil, err
+			}
+			n.elems = append(n.elems, elem)
+		}
+	}
+	return n, nil
+}
+
+func (n *schemaNode) write(e *Encoder) error {
+	switch n.tok.Kind() {
+	case TokenObjectOpen:
+		e.StartObject()
+		for _, m := range n.members {
+			if err := e.WriteName(m.name); err != nil {
+				return err
+			}
+			if err := m.value.write(e); err != nil {
+				return err
+			}
+		}
+		e.EndObject()
+
+	case TokenArrayOpen:
+		e.StartArray()
+		for _, elem := range n.elems {
+			if err := elem.write(e); err != nil {
+				return err
+			}
+		}
+		e.EndArray()
+
+	case TokenNull:
+		e.WriteNull()
+
+	case TokenBool:
+		e.WriteBool(n.tok.Bool())
+
+	case TokenString:
+		return e.WriteString(n.tok.ParsedString())
+
+	case TokenNumber:
+		// schemas only contain integers
+		if i, ok := n.tok.Int(64); ok {
+			e.WriteInt(i)
+		} else if u, ok := n.tok.Uint(64); ok {
+			e.WriteUint(u)
+		} else {
+			f, _ := n.tok.Float(64)
+			e.WriteFloat(f, 64)
+		}
+	}
+	return nil
+}
+
+// collectSchemaDefs adds the given message and all messages and enums it
+// references to defs.
+func collectSchemaDefs(md protoreflect.MessageDescriptor, defs map[protoreflect.FullName]protoreflect.Descriptor) {
+	if _, ok := defs[md.FullName()]; ok {
+		return
+	}
+	defs[md.FullName()] = md
+
+	switch md.FullName() {
+	case "google.protobuf.Struct":
+		// the fields map, whose entry is a synthetic message
+		collectSchemaDefs(md.Fields().Get(0).MapValue().Message(), defs)
+		return
+	case "google.protobuf.ListValue":
+		collectSchemaDefs(md.Fields().Get(0).Message(), defs)
+		return
+	}
+	if wellKnownTypeMarshaler(md.FullName()) != nil {
+		return
+	}
+
+	fds := md.Fields()
+	for i := 0; i < fds.Len(); i++ {
+		fd := fds.Get(i)
+		if fieldOptionsOf(fd).omit {
+			continue
+		}
+		if fd.IsMap() {
+			fd = fd.MapValue()
+		}
+		switch {
+		case fd.Message() != nil:
+			collectSchemaDefs(fd.Message(), defs)
+		case fd.Enum() != nil && fd.Enum().FullName() != NullValue_enum_fullname:
+			defs[fd.Enum().FullName()] = fd.Enum()
+		}
+	}
+}
+
+// schemaMessage writes the schema of the given message.
+func (e encoder) schemaMessage(md protoreflect.MessageDescriptor) error {
+	if wellKnownTypeMarshaler(md.FullName()) != nil {
+		e.schemaWellKnownType(md)
+		return nil
+	}
+
+	var fields []protoreflect.FieldDescriptor
+	fds := md.Fields()
+	for i := 0; i < fds.Len(); i++ {
+		if fd := fds.Get(i); !fieldOptionsOf(fd).omit {
+			fields = append(fields, fd)
+		}
+	}
+	if order := e.opts.fieldOrder(); order != nil {
+		sort.SliceStable(fields, func(i, j int) bool {
+			return order(fields[i], fields[j])
+		})
+	}
+
+	e.StartObject()
+	defer e.EndObject()
+
+	e.writeSchemaString("type", "object")
+	e.WriteName("properties")
+	e.StartObject()
+	for _, fd := range fields {
+		if err := e.WriteName(fieldName(fd, e.opts.UseProtoNames)); err != nil {
+			return err
+		}
+		fe := e
+		fe.int64AsNumber = e.opts.Int64AsNumber || fieldOptionsOf(fd).int64AsNumber
+		fe.schemaField(fd)
+	}
+	e.EndObject()
+	// extension fields are emitted as "[full.name]"
+	if md.ExtensionRanges().Len() == 0 {
+		e.WriteName("additionalProperties")
+		e.WriteBool(false)
+	}
+	return nil
+}
+
+// schemaField writes the schema of the given field.
+func (e encoder) schemaField(fd protoreflect.FieldDescriptor) {
+	switch {
+	case fd.IsList():
+		e.StartObject()
+		e.writeSchemaString("type", "array")
+		e.WriteName("items")
+		e.schemaSingular(fd)
+		e.EndObject()
+
+	case fd.IsMap():
+		e.StartObject()
+		e.writeSchemaString("type", "object")
+		switch fd.MapKey().Kind() {
+		case protoreflect.BoolKind:
+			e.WriteName("propertyNames")
+			e.StartObject()
+			e.WriteName("enum")
+			e.StartArray()
+			e.WriteString("true")
+			e.WriteString("false")
+			e.EndArray()
+			e.EndObject()
+		case protoreflect.StringKind:
+			// any string is a valid key
+		default:
+			e.WriteName("propertyNames")
+			e.StartObject()
+			e.writeSchemaString("pattern", integerPattern(fd.MapKey().Kind()))
+			e.EndObject()
+		}
+		e.WriteName("additionalProperties")
+		e.schemaSingular(fd.MapValue())
+		e.EndObject()
+
+	case e.schemaNullable(fd):
+		// unpopulated fields are emitted as null, see unpopulatedFieldRanger
+		e.StartObject()
+		e.WriteName("anyOf")
+		e.StartArray()
+		e.schemaSingular(fd)
+		e.StartObject()
+		e.writeSchemaString("type", "null")
+		e.EndObject()
+		e.EndArray()
+		e.EndObject()
+
+	default:
+		e.schemaSingular(fd)
+	}
+}
+
+// schemaNullable reports whether the given non-repeated field is emitted as
+// null when it is unpopulated.
+func (e encoder) schemaNullable(fd protoreflect.FieldDescriptor) bool {
+	switch {
+	case !e.opts.EmitUnpopulated:
+		return false
+	case fd.ContainingOneof() != nil:
+		return e.opts.UnpopulatedOneofs == OneofNull
+	default:
+		return fd.Syntax() == protoreflect.Proto2 && fd.Default().IsValid() || fd.Message() != nil
+	}
+}
+
+// schemaSingular writes the schema of a non-repeated value of the given field.
+func (e encoder) schemaSingular(fd protoreflect.FieldDescriptor) {
+	e.StartObject()
+	defer e.EndObject()
+
+	switch kind := fd.Kind(); kind {
+	case protoreflect.BoolKind:
+		e.writeSchemaString("type", "boolean")
+
+	case protoreflect.StringKind:
+		e.writeSchemaString("type", "string")
+
+	case protoreflect.BytesKind:
+		e.writeSchemaString("type", "string")
+		e.writeSchemaString("contentEncoding", "base64")
+
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		e.writeSchemaString("type", "integer")
+		e.WriteName("minimum")
+		e.WriteInt(-1 << 31)
+		e.WriteName("maximum")
+		e.WriteInt(1<<31 - 1)
+
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		e.writeSchemaString("type", "integer")
+		e.WriteName("minimum")
+		e.WriteInt(0)
+		e.WriteName("maximum")
+		e.WriteUint(1<<32 - 1)
+
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Uint64Kind,
+		protoreflect.Sfixed64Kind, protoreflect.Fixed64Kind:
+		e.schemaInt64(kind)
+
+	case protoreflect.FloatKind, protoreflect.DoubleKind:
+		// NaN and infinites are written as strings
+		e.WriteName("anyOf")
+		e.StartArray()
+		e.StartObject()
+		e.writeSchemaString("type", "number")
+		e.EndObject()
+		e.StartObject()
+		e.WriteName("enum")
+		e.StartArray()
+		e.WriteString("NaN")
+		e.WriteString("Infinity")
+		e.WriteString("-Infinity")
+		e.EndArray()
+		e.EndObject()
+		e.EndArray()
+
+	case protoreflect.EnumKind:
+		if fd.Enum().FullName() == NullValue_enum_fullname {
+			e.writeSchemaString("type", "null")
+		} else {
+			e.writeSchemaString("$ref", schemaDefsPrefix+string(fd.Enum().FullName()))
+		}
+
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		md := fd.Message()
+		if isInt64Wrapper(md.FullName()) && e.int64AsNumber != e.opts.Int64AsNumber {
+			// the wrapper follows (json_int64_as_number) of the field, so the
+			// shared definition does not apply
+			e.schemaInt64(md.Fields().ByNumber(wrapperValueFieldNumber).Kind())
+			return
+		}
+		e.writeSchemaString("$ref", schemaDefsPrefix+string(md.FullName()))
+	}
+}
+
+// schemaInt64 writes the keywords of the schema of a 64-bit integer, which is
+// a string unless numbers are asked for by the options or the field.
+func (e encoder) schemaInt64(kind protoreflect.Kind) {
+	unsigned := kind == protoreflect.Uint64Kind || kind == protoreflect.Fixed64Kind
+	switch {
+	case !e.int64AsNumber:
+		e.writeSchemaString("type", "string")
+		e.writeSchemaString("pattern", integerPattern(kind))
+
+	case e.opts.Int64SafeRange:
+		// integers out of the safe range are still written as strings
+		e.WriteName("anyOf")
+		e.StartArray()
+		e.StartObject()
+		e.writeSchemaString("type", "integer")
+		e.WriteName("minimum")
+		if unsigned {
+			e.WriteInt(0)
+		} else {
+			e.WriteInt(-maxSafeInteger)
+		}
+		e.WriteName("maximum")
+		e.WriteInt(maxSafeInteger)
+		e.EndObject()
+		e.StartObject()
+		e.writeSchemaString("type", "string")
+		e.writeSchemaString("pattern", integerPattern(kind))
+		e.EndObject()
+		e.EndArray()
+
+	default:
+		e.writeSchemaString("type", "integer")
+		if unsigned {
+			e.WriteName("minimum")
+			e.WriteInt(0)
+		}
+	}
+}
+
+// schemaEnum writes the schema of the given enum.
+func (e encoder) schemaEnum(ed protoreflect.EnumDescriptor) {
+	e.StartObject()
+	defer e.EndObject()
+
+	values := ed.Values()
+	if e.opts.UseEnumNumbers {
+		e.writeSchemaString("type", "integer")
+	} else {
+		e.writeSchemaString("type", "string")
+	}
+	e.WriteName("enum")
+	e.StartArray()
+	for i := 0; i < values.Len(); i++ {
+		if e.opts.UseEnumNumbers {
+			e.WriteInt(int64(values.Get(i).Number()))
+		} else {
+			e.WriteString(string(values.Get(i).Name()))
+		}
+	}
+	e.EndArray()
+}
+
+// schemaWellKnownType writes the schema of the special JSON form of the given
+// well-known type.
+func (e encoder) schemaWellKnownType(md protoreflect.MessageDescriptor) {
+	switch md.Name() {
+	case "BoolValue", "Int32Value", "Int64Value", "UInt32Value", "UInt64Value",
+		"FloatValue", "DoubleValue", "StringValue", "BytesValue":
+		e.schemaSingular(md.Fields().ByNumber(wrapperValueFieldNumber))
+		return
+	}
+
+	e.StartObject()
+	defer e.EndObject()
+
+	switch md.Name() {
+	case "Any":
+		e.writeSchemaString("type", "object")
+		e.WriteName("properties")
+		e.StartObject()
+		e.WriteName(anyTypeFieldName)
+		e.StartObject()
+		e.writeSchemaString("type", "string")
+		e.EndObject()
+		e.EndObject()
+		e.WriteName("required")
+		e.StartArray()
+		e.WriteString(anyTypeFieldName)
+		e.EndArray()
+	case "Timestamp":
+		e.writeSchemaString("type", "string")
+		e.writeSchemaString("format", "date-time")
+	case "Duration":
+		e.writeSchemaString("type", "string")
+		e.writeSchemaString("pattern", `^-?[0-9]+(\.[0-9]+)?s$`)
+	case "FieldMask":
+		e.writeSchemaString("type", "string")
+	case "Struct":
+		e.writeSchemaString("type", "object")
+		e.WriteName("additionalProperties")
+		e.StartObject()
+		e.writeSchemaString("$ref", schemaDefsPrefix+Value_message_fullname)
+		e.EndObject()
+	case "ListValue":
+		e.writeSchemaString("type", "array")
+		e.WriteName("items")
+		e.StartObject()
+		e.writeSchemaString("$ref", schemaDefsPrefix+Value_message_fullname)
+		e.EndObject()
+	case "Empty":
+		e.writeSchemaString("type", "object")
+		e.WriteName("maxProperties")
+		e.WriteInt(0)
+	}
+	// google.protobuf.Value is any JSON value, the empty schema
+}
+
+// writeSchemaString writes a schema keyword with a string value.
+func (e encoder) writeSchemaString(name, value string) {
+	e.WriteName(name)
+	e.WriteString(value)
+}
+
+// integerPattern returns the pattern of an integer of the given kind written
+// as a string.
+func integerPattern(kind protoreflect.Kind) string {
+	switch kind {
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind,
+		protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		return "^[0-9]+$"
+	default:
+		return "^-?[0-9]+$"
+	}
+}
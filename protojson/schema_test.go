@@ -0,0 +1,301 @@
+package protojson
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func TestSchema(t *testing.T) {
+	b, err := Schema((&HelloRequest{}).ProtoReflect().Descriptor(), MarshalOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := `{"$schema":"https://json-schema.org/draft/2020-12/schema","$ref":"#/$defs/HelloRequest","$defs":{` +
+		`"HelloRequest":{"type":"object","properties":{` +
+		`"success":{"type":"boolean"},` +
+		`"score":{"anyOf":[{"type":"number"},{"enum":["NaN","Infinity","-Infinity"]}]},` +
+		`"age":{"type":"integer","minimum":-2147483648,"maximum":2147483647},` +
+		`"timestamp":{"type":"string","pattern":"^-?[0-9]+$"},` +
+		`"data":{"type":"string","contentEncoding":"base64"},` +
+		`"tags":{"type":"array","items":{"type":"string"}},` +
+		`"labels":{"type":"object","additionalProperties":{"type":"string"}},` +
+		`"any":{"$ref":"#/$defs/google.protobuf.Any"}},"additionalProperties":false},` +
+		`"google.protobuf.Any":{"type":"object","properties":{"@type":{"type":"string"}},"required":["@type"]}}}`
+	if string(b) != expect {
+		t.Errorf("expect %s, but got %s", expect, b)
+	}
+}
+
+func TestSchemaOptions(t *testing.T) {
+	schemaOf := func(b []byte, err error) map[string]interface{} {
+		t.Helper()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var schema map[string]interface{}
+		if err := json.Unmarshal(b, &schema); err != nil {
+			t.Fatal(err)
+		}
+		return schema
+	}
+	lookup := func(v interface{}, path ...string) interface{} {
+		for _, p := range path {
+			m, ok := v.(map[string]interface{})
+			if !ok {
+				return nil
+			}
+			v = m[p]
+		}
+		return v
+	}
+
+	field := (&descriptorpb.FieldDescriptorProto{}).ProtoReflect().Descriptor()
+	legacy := newLegacyMessage(t)
+	order := newOrderMessage(t)
+	account := newRedactMessage(t)
+	wrapped := newWrappedMessage(t)
+	tests := []struct {
+		name   string
+		schema map[string]interface{}
+		path   []string
+		expect string
+	}{
+		{
+			"override",
+			schemaOf(Schema(legacy, MarshalOptions{UseProtoNames: true})),
+			[]string{"$defs", "legacy.Legacy", "properties", "UserName"},
+			`{"type":"string"}`,
+		},
+		{
+			"omit",
+			schemaOf(Schema(legacy, MarshalOptions{})),
+			[]string{"$defs", "legacy.Legacy", "properties", "password"},
+			`null`,
+		},
+		{
+			"int64 field option",
+			schemaOf(Schema(legacy, MarshalOptions{})),
+			[]string{"$defs", "legacy.Legacy", "properties", "totals"},
+			`{"additionalProperties":{"type":"integer"},"type":"object"}`,
+		},
+		{
+			"int64 map key",
+			schemaOf(Schema(legacy, MarshalOptions{Int64AsNumber: true})),
+			[]string{"$defs", "legacy.Legacy", "properties", "counts"},
+			`{"additionalProperties":{"minimum":0,"type":"integer"},"propertyNames":{"pattern":"^-?[0-9]+$"},"type":"object"}`,
+		},
+		{
+			"int64 safe range",
+			schemaOf(Schema(legacy, MarshalOptions{Int64AsNumber: true, Int64SafeRange: true})),
+			[]string{"$defs", "legacy.Legacy", "properties", "plain"},
+			`{"anyOf":[{"maximum":9007199254740991,"minimum":-9007199254740991,"type":"integer"},{"pattern":"^-?[0-9]+$","type":"string"}]}`,
+		},
+		{
+			"int64 wrapper field option",
+			schemaOf(Schema(wrapped, MarshalOptions{})),
+			[]string{"$defs", "wrapped.Wrapped", "properties", "id"},
+			`{"type":"integer"}`,
+		},
+		{
+			"repeated uint64 wrapper field option",
+			schemaOf(Schema(wrapped, MarshalOptions{})),
+			[]string{"$defs", "wrapped.Wrapped", "properties", "ids"},
+			`{"items":{"minimum":0,"type":"integer"},"type":"array"}`,
+		},
+		{
+			"int64 wrapper",
+			schemaOf(Schema(wrapped, MarshalOptions{})),
+			[]string{"$defs", "wrapped.Wrapped", "properties", "plain"},
+			`{"$ref":"#/$defs/google.protobuf.Int64Value"}`,
+		},
+		{
+			"repeated message",
+			schemaOf(Schema(order, MarshalOptions{})),
+			[]string{"$defs", "order.Order", "properties", "items"},
+			`{"items":{"$ref":"#/$defs/order.Item"},"type":"array"}`,
+		},
+		{
+			"map message",
+			schemaOf(Schema(order, MarshalOptions{UseProtoNames: true})),
+			[]string{"$defs", "order.Item", "properties", "sku_code"},
+			`{"type":"string"}`,
+		},
+		{
+			"timestamp",
+			schemaOf(Schema(order, MarshalOptions{})),
+			[]string{"$defs", "google.protobuf.Timestamp"},
+			`{"format":"date-time","type":"string"}`,
+		},
+		{
+			"unpopulated message",
+			schemaOf(Schema(order, MarshalOptions{EmitUnpopulated: true})),
+			[]string{"$defs", "order.Order", "properties", "gift"},
+			`{"anyOf":[{"$ref":"#/$defs/order.Item"},{"type":"null"}]}`,
+		},
+		{
+			"enum",
+			schemaOf(Schema(field, MarshalOptions{})),
+			[]string{"$defs", "google.protobuf.FieldDescriptorProto.Label"},
+			`{"enum":["LABEL_OPTIONAL","LABEL_REQUIRED","LABEL_REPEATED"],"type":"string"}`,
+		},
+		{
+			"enum numbers",
+			schemaOf(Schema(field, MarshalOptions{UseEnumNumbers: true})),
+			[]string{"$defs", "google.protobuf.FieldDescriptorProto.Label"},
+			`{"enum":[1,2,3],"type":"integer"}`,
+		},
+		{
+			"unpopulated proto2 scalar",
+			schemaOf(Schema(field, MarshalOptions{EmitUnpopulated: true})),
+			[]string{"$defs", "google.protobuf.FieldDescriptorProto", "properties", "label"},
+			`{"anyOf":[{"$ref":"#/$defs/google.protobuf.FieldDescriptorProto.Label"},{"type":"null"}]}`,
+		},
+		{
+			"recursion",
+			schemaOf(Schema(account, MarshalOptions{})),
+			[]string{"$defs", "redact.Account", "properties", "parent"},
+			`{"$ref":"#/$defs/redact.Account"}`,
+		},
+	}
+	for _, test := range tests {
+		var expect interface{}
+		if err := json.Unmarshal([]byte(test.expect), &expect); err != nil {
+			t.Fatal(err)
+		}
+		if got := lookup(test.schema, test.path...); !reflect.DeepEqual(got, expect) {
+			b, _ := json.Marshal(got)
+			t.Errorf("%s: expect %s, but got %s", test.name, test.expect, b)
+		}
+	}
+}
+
+func TestSchemaDefs(t *testing.T) {
+	tests := []struct {
+		desc   protoreflect.MessageDescriptor
+		expect []string
+	}{
+		{(&structpb.Struct{}).ProtoReflect().Descriptor(), []string{"google.protobuf.Struct", "google.protobuf.Value"}},
+		{(&structpb.ListValue{}).ProtoReflect().Descriptor(), []string{"google.protobuf.ListValue", "google.protobuf.Value"}},
+		{(&structpb.Value{}).ProtoReflect().Descriptor(), []string{"google.protobuf.Value"}},
+	}
+	for _, test := range tests {
+		b, err := Schema(test.desc, MarshalOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var schema struct {
+			Defs map[string]json.RawMessage `json:"$defs"`
+		}
+		if err := json.Unmarshal(b, &schema); err != nil {
+			t.Fatal(err)
+		}
+		names := make([]string, 0, len(schema.Defs))
+		for name := range schema.Defs {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		if !reflect.DeepEqual(names, test.expect) {
+			t.Errorf("%s: expect %v, but got %v", test.desc.FullName(), test.expect, names)
+		}
+	}
+}
+
+func TestSchemaInt64Wrappers(t *testing.T) {
+	tests := []struct {
+		m      proto.Message
+		expect string
+	}{
+		{wrapperspb.Int64(-3), `{"type":"integer"}`},
+		{wrapperspb.UInt64(3), `{"type":"integer","minimum":0}`},
+	}
+	opts := MarshalOptions{Int64AsNumber: true}
+	for _, test := range tests {
+		name := test.m.ProtoReflect().Descriptor().FullName()
+		b, err := Schema(test.m.ProtoReflect().Descriptor(), opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var schema struct {
+			Defs map[string]json.RawMessage `json:"$defs"`
+		}
+		if err := json.Unmarshal(b, &schema); err != nil {
+			t.Fatal(err)
+		}
+		if got := string(schema.Defs[string(name)]); got != test.expect {
+			t.Errorf("%s: expect %s, but got %s", name, test.expect, got)
+		}
+
+		// the schema accepts the output of the encoder
+		out, err := opts.Marshal(test.m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.HasPrefix(string(out), `"`) {
+			t.Errorf("%s: expect a number, but got %s", name, out)
+		}
+	}
+}
+
+func TestSchemaMultiline(t *testing.T) {
+	md := (&HelloRequest{}).ProtoReflect().Descriptor()
+	a, err := Schema(md, MarshalOptions{Multiline: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := Schema(md, MarshalOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var x, y interface{}
+	if err := json.Unmarshal(a, &x); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(b, &y); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(x, y) {
+		t.Errorf("expect %s, but got %s", b, a)
+	}
+}
+
+func TestSchemaCanonical(t *testing.T) {
+	md := (&HelloRequest{}).ProtoReflect().Descriptor()
+	a, err := Schema(md, MarshalOptions{Canonical: true, Multiline: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := Schema(md, MarshalOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// encoding/json sorts map keys, which matches the UTF-16 order for the
+	// ASCII names of the schema
+	var x interface{}
+	if err := json.Unmarshal(b, &x); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(x); err != nil {
+		t.Fatal(err)
+	}
+	expect := strings.TrimSuffix(buf.String(), "\n")
+	if string(a) != expect {
+		t.Errorf("expect %s, but got %s", expect, a)
+	}
+	if string(a) == string(b) {
+		t.Error("expect sorted keys to differ from the default order")
+	}
+}
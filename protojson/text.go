@@ -0,0 +1,289 @@
+package protojson
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"unicode/utf8"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// MarshalText writes the given proto.Message in the protobuf text format using
+// default options.
+func MarshalText(m proto.Message) ([]byte, error) {
+	return MarshalOptions{}.MarshalText(m)
+}
+
+// MarshalText writes the given proto.Message in the protobuf text format using
+// options in MarshalOptions, the output can be read back by prototext. It
+// shares the traversal with Marshal, so MessageRanger, EmitUnpopulated,
+// FieldOrder, MapKeyOrder, FieldMask and Redact apply alike, and
+// google.protobuf.Any is expanded with Resolver when its type is found.
+// Multiline and Indent lay out the fields one per line, UseEnumNumbers writes
+// enum numbers and MaxDepth and MaxBytes limit the output alike. The other
+// options only concern JSON: fields are named by their proto names, 64-bit
+// integers are numbers and well-known types are written as regular messages.
+// Fields emitted as null by EmitUnpopulated are left out as the text format
+// has no null.
+func (o MarshalOptions) MarshalText(m proto.Message) ([]byte, error) {
+	o.Canonical = false
+	o = o.withDefaults()
+	if m == nil {
+		return []byte{}, nil
+	}
+
+	mask, err := newFieldMaskTree(m.ProtoReflect().Descriptor(), o.FieldMask)
+	if err != nil {
+		return nil, err
+	}
+
+	e := textEncoder{opts: o}
+	if err := e.marshalFields(m.ProtoReflect(), mask); err != nil {
+		return nil, err
+	}
+	if o.Indent != "" && len(e.out) > 0 {
+		e.out = append(e.out, '\n')
+	}
+	if o.MaxBytes > 0 && len(e.out) > o.MaxBytes {
+		return nil, &LimitError{Limit: LimitBytes, Max: o.MaxBytes}
+	}
+	return e.out, nil
+}
+
+// textEncoder writes the protobuf text format.
+type textEncoder struct {
+	opts  MarshalOptions
+	out   []byte
+	depth int
+	// level is the nesting of the current message, see MaxDepth.
+	level int
+	// empty reports whether no field is written in the current message yet.
+	empty bool
+}
+
+// marshalFields writes the fields of the given message without braces.
+func (e *textEncoder) marshalFields(m protoreflect.Message, mask *fieldMaskNode) error {
+	if e.level++; e.level > e.opts.MaxDepth {
+		return &LimitError{Limit: LimitDepth, Max: e.opts.MaxDepth}
+	}
+	defer func() { e.level-- }()
+
+	e.empty = true
+	if ok, err := e.marshalAny(m); ok || err != nil {
+		return err
+	}
+
+	var err error
+	RangeFields(e.opts.fieldRanger(m, mask), e.opts.fieldOrder(), func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		_, child := mask.selected(fd)
+		switch {
+		case !v.IsValid():
+			// unpopulated fields emitted as null
+		case fd.IsList():
+			list := v.List()
+			if list.Len() == 0 {
+				e.writeName(fd.TextName())
+				e.out = append(e.out, "[]"...)
+			}
+			for i := 0; i < list.Len() && err == nil; i++ {
+				e.writeName(fd.TextName())
+				err = e.marshalSingular(list.Get(i), fd, child)
+			}
+		case fd.IsMap():
+			RangeEntries(v.Map(), e.opts.mapKeyOrder(), func(k protoreflect.MapKey, v protoreflect.Value) bool {
+				e.writeName(fd.TextName())
+				err = e.marshalEntry(fd, k, v, child)
+				return err == nil
+			})
+		default:
+			e.writeName(fd.TextName())
+			err = e.marshalSingular(v, fd, child)
+		}
+		if err == nil && e.opts.MaxBytes > 0 && len(e.out) > e.opts.MaxBytes {
+			err = &LimitError{Limit: LimitBytes, Max: e.opts.MaxBytes}
+		}
+		return err == nil
+	})
+	return err
+}
+
+// marshalAny writes the expanded form of google.protobuf.Any, e.g.
+// [type.googleapis.com/foo.Bar]: {...}. It reports false if m is not an Any or
+// its type cannot be resolved, then the regular fields are written.
+func (e *textEncoder) marshalAny(m protoreflect.Message) (bool, error) {
+	if m.Descriptor().FullName() != "google.protobuf.Any" {
+		return false, nil
+	}
+	fds := m.Descriptor().Fields()
+	typeURL := m.Get(fds.ByNumber(anyTypeURLFieldNumber)).String()
+	if typeURL == "" {
+		return false, nil
+	}
+	emt, err := e.opts.Resolver.FindMessageByURL(typeURL)
+	if err != nil {
+		return false, nil
+	}
+
+	em := emt.New()
+	err = proto.UnmarshalOptions{
+		AllowPartial: true, // never check required fields inside an Any
+		Resolver:     e.opts.Resolver,
+	}.Unmarshal(m.Get(fds.ByNumber(anyValueFieldNumber)).Bytes(), em.Interface())
+	if err != nil {
+		return false, nil
+	}
+
+	e.writeName("[" + typeURL + "]")
+	return true, e.marshalMessage(em, nil)
+}
+
+// marshalEntry writes a map entry as a message with the key and value fields.
+func (e *textEncoder) marshalEntry(fd protoreflect.FieldDescriptor, k protoreflect.MapKey, v protoreflect.Value, mask *fieldMaskNode) error {
+	e.startMessage()
+	e.writeName("key")
+	if err := e.marshalSingular(k.Value(), fd.MapKey(), nil); err != nil {
+		return err
+	}
+	e.writeName("value")
+	if err := e.marshalSingular(v, fd.MapValue(), mask); err != nil {
+		return err
+	}
+	e.endMessage()
+	return nil
+}
+
+// marshalMessage writes the given message in braces.
+func (e *textEncoder) marshalMessage(m protoreflect.Message, mask *fieldMaskNode) error {
+	e.startMessage()
+	if err := e.marshalFields(m, mask); err != nil {
+		return err
+	}
+	e.endMessage()
+	return nil
+}
+
+func (e *textEncoder) startMessage() {
+	e.out = append(e.out, '{')
+	e.depth++
+	e.empty = true
+}
+
+func (e *textEncoder) endMessage() {
+	e.depth--
+	if !e.empty && e.opts.Indent != "" {
+		e.writeIndent()
+	}
+	e.out = append(e.out, '}')
+	e.empty = false
+}
+
+// writeName writes the field name, separated from the previous field.
+func (e *textEncoder) writeName(name string) {
+	switch {
+	case e.opts.Indent != "":
+		if len(e.out) > 0 {
+			e.writeIndent()
+		}
+	case !e.empty:
+		e.out = append(e.out, ' ')
+	}
+	e.empty = false
+	e.out = append(e.out, name...)
+	e.out = append(e.out, ": "...)
+}
+
+func (e *textEncoder) writeIndent() {
+	e.out = append(e.out, '\n')
+	for i := 0; i < e.depth; i++ {
+		e.out = append(e.out, e.opts.Indent...)
+	}
+}
+
+// marshalSingular writes the given non-repeated field value.
+func (e *textEncoder) marshalSingular(val protoreflect.Value, fd protoreflect.FieldDescriptor, mask *fieldMaskNode) error {
+	switch kind := fd.Kind(); kind {
+	case protoreflect.BoolKind:
+		e.out = strconv.AppendBool(e.out, val.Bool())
+
+	case protoreflect.StringKind:
+		s := val.String()
+		if !utf8.ValidString(s) {
+			return fmt.Errorf("proto: field %s contains invalid UTF-8", string(fd.FullName()))
+		}
+		e.out = appendTextString(e.out, s)
+
+	case protoreflect.BytesKind:
+		e.out = appendTextString(e.out, string(val.Bytes()))
+
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind,
+		protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		e.out = strconv.AppendInt(e.out, val.Int(), 10)
+
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind,
+		protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		e.out = strconv.AppendUint(e.out, val.Uint(), 10)
+
+	case protoreflect.FloatKind, protoreflect.DoubleKind:
+		e.out = appendTextFloat(e.out, val.Float(), kind)
+
+	case protoreflect.EnumKind:
+		num := val.Enum()
+		if desc := fd.Enum().Values().ByNumber(num); desc != nil && !e.opts.UseEnumNumbers {
+			e.out = append(e.out, desc.Name()...)
+		} else {
+			e.out = strconv.AppendInt(e.out, int64(num), 10)
+		}
+
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		return e.marshalMessage(val.Message(), mask)
+
+	default:
+		panic(fmt.Sprintf("%v has unknown kind: %v", fd.FullName(), kind))
+	}
+	return nil
+}
+
+// appendTextFloat appends the float in the text format, which spells the
+// special numbers inf, -inf and nan.
+func appendTextFloat(out []byte, n float64, kind protoreflect.Kind) []byte {
+	switch {
+	case math.IsNaN(n):
+		return append(out, "nan"...)
+	case math.IsInf(n, +1):
+		return append(out, "inf"...)
+	case math.IsInf(n, -1):
+		return append(out, "-inf"...)
+	case kind == protoreflect.FloatKind:
+		return strconv.AppendFloat(out, n, 'g', -1, 32)
+	default:
+		return strconv.AppendFloat(out, n, 'g', -1, 64)
+	}
+}
+
+// appendTextString appends s as a double-quoted string of the text format.
+// Printable UTF-8 is kept as is, other bytes are escaped in octal.
+func appendTextString(out []byte, s string) []byte {
+	out = append(out, '"')
+	for i := 0; i < len(s); {
+		r, n := utf8.DecodeRuneInString(s[i:])
+		switch {
+		case r == '"' || r == '\\':
+			out = append(out, '\\', byte(r))
+		case r == '\n':
+			out = append(out, '\\', 'n')
+		case r == '\r':
+			out = append(out, '\\', 'r')
+		case r == '\t':
+			out = append(out, '\\', 't')
+		case r == utf8.RuneError && n == 1, r < ' ', r == 0x7f:
+			c := s[i]
+			out = append(out, '\\', '0'+c>>6, '0'+c>>3&7, '0'+c&7)
+		default:
+			out = append(out, s[i:i+n]...)
+		}
+		i += n
+	}
+	return append(out, '"')
+}
@@ -0,0 +1,98 @@
+package protojson
+
+import (
+	"testing"
+
+	"google.golang.org/protobuf/encoding/prototext"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+)
+
+func newTextHelloRequest(t *testing.T) *HelloRequest {
+	a, err := anypb.New(&Person{Name: "bob", Like: "book", Age: 18})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &HelloRequest{
+		Success:   true,
+		Score:     1.5,
+		Age:       3,
+		Timestamp: 9,
+		Data:      []byte("a\x00\xff"),
+		Tags:      []string{"x", "y\n\"z"},
+		Labels:    map[string]string{"b": "2", "true": "1"},
+		Any:       a,
+	}
+}
+
+func TestMarshalText(t *testing.T) {
+	hello := newTextHelloRequest(t)
+
+	tests := []struct {
+		name   string
+		opts   MarshalOptions
+		expect string
+	}{
+		{"default", MarshalOptions{}, `success: true score: 1.5 age: 3 timestamp: 9 data: "a\000\377" tags: "x" tags: "y\n\"z" labels: {key: "b" value: "2"} labels: {key: "true" value: "1"} any: {[type.googleapis.com/Person]: {name: "bob" like: "book" age: 18}}`},
+		{"redact", MarshalOptions{Redact: &Redaction{}, FieldOrder: NumberFieldOrder, MapKeyOrder: func(x, y protoreflect.MapKey) bool { return x.String() > y.String() }},
+			`success: true score: 1.5 age: 3 timestamp: 9 data: "a\000\377" tags: "x" tags: "y\n\"z" labels: {key: "true" value: "1"} labels: {key: "b" value: "2"} any: {[type.googleapis.com/Person]: {name: "***" like: "book" age: 18}}`},
+		{"field mask", MarshalOptions{FieldMask: &fieldmaskpb.FieldMask{Paths: []string{"age", "labels"}}}, `age: 3 labels: {key: "b" value: "2"} labels: {key: "true" value: "1"}`},
+		{"multiline", MarshalOptions{Multiline: true, FieldMask: &fieldmaskpb.FieldMask{Paths: []string{"tags", "labels", "any"}}}, `tags: "x"
+tags: "y\n\"z"
+labels: {
+  key: "b"
+  value: "2"
+}
+labels: {
+  key: "true"
+  value: "1"
+}
+any: {
+  [type.googleapis.com/Person]: {
+    name: "bob"
+    like: "book"
+    age: 18
+  }
+}
+`},
+		{"unpopulated", MarshalOptions{EmitUnpopulated: true, FieldMask: &fieldmaskpb.FieldMask{Paths: []string{"age"}}}, `age: 3`},
+	}
+	for _, test := range tests {
+		b, err := test.opts.MarshalText(hello)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != test.expect {
+			t.Errorf("%s: expect %s, but got %s", test.name, test.expect, b)
+		}
+
+		var got HelloRequest
+		if err := prototext.Unmarshal(b, &got); err != nil {
+			t.Errorf("%s: %v", test.name, err)
+		}
+	}
+
+	for _, opts := range []MarshalOptions{{}, {Multiline: true}, {EmitUnpopulated: true}} {
+		b, err := opts.MarshalText(hello)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got HelloRequest
+		if err := prototext.Unmarshal(b, &got); err != nil {
+			t.Fatal(err)
+		}
+		if !proto.Equal(&got, hello) {
+			t.Errorf("expect %v, but got %v", hello, &got)
+		}
+	}
+
+	b, err := MarshalOptions{EmitUnpopulated: true}.MarshalText(&HelloRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expect := `success: false score: 0 age: 0 timestamp: 0 data: "" tags: []`; string(b) != expect {
+		t.Errorf("expect %s, but got %s", expect, b)
+	}
+}
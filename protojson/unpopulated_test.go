@@ -0,0 +1,194 @@
+package protojson
+
+import (
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+func unpopulatedField(name string, num int32, typ descriptorpb.FieldDescriptorProto_Type) *descriptorpb.FieldDescriptorProto {
+	return &descriptorpb.FieldDescriptorProto{
+		Name:     proto.String(name),
+		JsonName: proto.String(jsonCamelCase(name)),
+		Number:   proto.Int32(num),
+		Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+		Type:     typ.Enum(),
+	}
+}
+
+// newShapeFile builds the following proto2 file at runtime:
+//
+//	package unpop2;
+//	message Shape {
+//	  optional string name = 1;
+//	  oneof kind {
+//	    int32 radius = 2;
+//	    Shape child = 3;
+//	  }
+//	  extensions 100 to 200;
+//	}
+//	extend Shape {
+//	  optional int32 weight = 100;
+//	  repeated string notes = 101;
+//	}
+func newShapeFile(t *testing.T) protoreflect.FileDescriptor {
+	radius := unpopulatedField("radius", 2, descriptorpb.FieldDescriptorProto_TYPE_INT32)
+	radius.OneofIndex = proto.Int32(0)
+	child := unpopulatedField("child", 3, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE)
+	child.OneofIndex = proto.Int32(0)
+	child.TypeName = proto.String(".unpop2.Shape")
+	weight := unpopulatedField("weight", 100, descriptorpb.FieldDescriptorProto_TYPE_INT32)
+	weight.Extendee = proto.String(".unpop2.Shape")
+	notes := unpopulatedField("notes", 101, descriptorpb.FieldDescriptorProto_TYPE_STRING)
+	notes.Extendee = proto.String(".unpop2.Shape")
+	notes.Label = descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum()
+
+	fdp := &descriptorpb.FileDescriptorProto{
+		Name:    proto.String("unpop2_test.proto"),
+		Package: proto.String("unpop2"),
+		Syntax:  proto.String("proto2"),
+		MessageType: []*descriptorpb.DescriptorProto{{
+			Name: proto.String("Shape"),
+			Field: []*descriptorpb.FieldDescriptorProto{
+				unpopulatedField("name", 1, descriptorpb.FieldDescriptorProto_TYPE_STRING),
+				radius,
+				child,
+			},
+			OneofDecl: []*descriptorpb.OneofDescriptorProto{{Name: proto.String("kind")}},
+			ExtensionRange: []*descriptorpb.DescriptorProto_ExtensionRange{{
+				Start: proto.Int32(100),
+				End:   proto.Int32(201),
+			}},
+		}},
+		Extension: []*descriptorpb.FieldDescriptorProto{weight, notes},
+	}
+
+	fd, err := protodesc.NewFile(fdp, protoregistry.GlobalFiles)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fd
+}
+
+// newEventMessage builds the following proto3 message at runtime:
+//
+//	message Event {
+//	  string id = 1;
+//	  oneof payload {
+//	    string text = 2;
+//	    bytes blob = 3;
+//	  }
+//	  optional int64 seq = 4;
+//	}
+func newEventMessage(t *testing.T) protoreflect.MessageDescriptor {
+	text := unpopulatedField("text", 2, descriptorpb.FieldDescriptorProto_TYPE_STRING)
+	text.OneofIndex = proto.Int32(0)
+	blob := unpopulatedField("blob", 3, descriptorpb.FieldDescriptorProto_TYPE_BYTES)
+	blob.OneofIndex = proto.Int32(0)
+	seq := unpopulatedField("seq", 4, descriptorpb.FieldDescriptorProto_TYPE_INT64)
+	seq.OneofIndex = proto.Int32(1)
+	seq.Proto3Optional = proto.Bool(true)
+
+	fdp := &descriptorpb.FileDescriptorProto{
+		Name:    proto.String("unpop3_test.proto"),
+		Package: proto.String("unpop3"),
+		Syntax:  proto.String("proto3"),
+		MessageType: []*descriptorpb.DescriptorProto{{
+			Name: proto.String("Event"),
+			Field: []*descriptorpb.FieldDescriptorProto{
+				unpopulatedField("id", 1, descriptorpb.FieldDescriptorProto_TYPE_STRING),
+				text,
+				blob,
+				seq,
+			},
+			OneofDecl: []*descriptorpb.OneofDescriptorProto{
+				{Name: proto.String("payload")},
+				{Name: proto.String("_seq")},
+			},
+		}},
+	}
+
+	fd, err := protodesc.NewFile(fdp, protoregistry.GlobalFiles)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fd.Messages().Get(0)
+}
+
+func TestMarshalUnpopulatedOneofs(t *testing.T) {
+	md := newEventMessage(t)
+	empty := dynamicpb.NewMessage(md)
+	set := dynamicpb.NewMessage(md)
+	set.Set(md.Fields().ByName("blob"), protoreflect.ValueOfBytes([]byte("a")))
+
+	tests := []struct {
+		name   string
+		opts   MarshalOptions
+		m      proto.Message
+		expect string
+	}{
+		{"skip", MarshalOptions{EmitUnpopulated: true}, empty, `{"id":""}`},
+		{"null", MarshalOptions{EmitUnpopulated: true, UnpopulatedOneofs: OneofNull}, empty, `{"id":"","text":null,"blob":null,"seq":null}`},
+		{"default", MarshalOptions{EmitUnpopulated: true, UnpopulatedOneofs: OneofDefault}, empty, `{"id":"","text":"","seq":"0"}`},
+		{"set", MarshalOptions{EmitUnpopulated: true, UnpopulatedOneofs: OneofNull}, set, `{"id":"","blob":"YQ==","seq":null}`},
+		{"without unpopulated", MarshalOptions{UnpopulatedOneofs: OneofNull}, empty, `{}`},
+	}
+	for _, test := range tests {
+		b, err := test.opts.Marshal(test.m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != test.expect {
+			t.Errorf("%s: expect %s, but got %s", test.name, test.expect, b)
+		}
+	}
+}
+
+func TestMarshalUnpopulatedExtensions(t *testing.T) {
+	fd := newShapeFile(t)
+	md := fd.Messages().Get(0)
+	var types protoregistry.Types
+	for i := 0; i < fd.Extensions().Len(); i++ {
+		if err := types.RegisterExtension(dynamicpb.NewExtensionType(fd.Extensions().Get(i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	weight, err := types.FindExtensionByName("unpop2.weight")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	empty := dynamicpb.NewMessage(md)
+	set := dynamicpb.NewMessage(md)
+	set.Set(weight.TypeDescriptor(), protoreflect.ValueOfInt32(3))
+	set.Set(md.Fields().ByName("child"), protoreflect.ValueOfMessage(dynamicpb.NewMessage(md)))
+
+	tests := []struct {
+		name   string
+		opts   MarshalOptions
+		m      proto.Message
+		expect string
+	}{
+		{"unpopulated", MarshalOptions{EmitUnpopulated: true, Resolver: &types}, empty, `{"name":null}`},
+		{"extensions", MarshalOptions{EmitUnpopulated: true, EmitUnpopulatedExtensions: true, Resolver: &types}, empty, `{"name":null,"[unpop2.notes]":[],"[unpop2.weight]":null}`},
+		{"oneofs", MarshalOptions{EmitUnpopulated: true, EmitUnpopulatedExtensions: true, UnpopulatedOneofs: OneofDefault, Resolver: &types, UseProtoNames: true},
+			empty, `{"name":null,"radius":0,"[unpop2.notes]":[],"[unpop2.weight]":null}`},
+		{"set", MarshalOptions{EmitUnpopulated: true, EmitUnpopulatedExtensions: true, UnpopulatedOneofs: OneofNull, Resolver: &types},
+			set, `{"name":null,"child":{"name":null,"radius":null,"child":null,"[unpop2.notes]":[],"[unpop2.weight]":null},"[unpop2.notes]":[],"[unpop2.weight]":3}`},
+		{"global types", MarshalOptions{EmitUnpopulated: true, EmitUnpopulatedExtensions: true}, empty, `{"name":null}`},
+	}
+	for _, test := range tests {
+		b, err := test.opts.Marshal(test.m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != test.expect {
+			t.Errorf("%s: expect %s, but got %s", test.name, test.expect, b)
+		}
+	}
+}
@@ -0,0 +1,870 @@
+package protojson
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+const (
+	// Package name of the well-known types.
+	googleProtobufPackage protoreflect.FullName = "google.protobuf"
+
+	// Field numbers and names of the well-known types.
+	anyTypeURLFieldNumber       = 1
+	anyValueFieldNumber         = 2
+	wrapperValueFieldNumber     = 1
+	secondsFieldNumber          = 1
+	nanosFieldNumber            = 2
+	structFieldsFieldNumber     = 1
+	listValueValuesFieldNumber  = 1
+	valueNumberValueFieldNumber = 2
+	fieldMaskPathsFieldNumber   = 1
+	valueKindOneofName          = "kind"
+
+	// JSON field names used by the google.protobuf.Any representation.
+	anyTypeFieldName  = "@type"
+	anyValueFieldName = "value"
+
+	// Range limits of google.protobuf.Duration and google.protobuf.Timestamp.
+	secondsInNanos       = 999999999
+	maxSecondsInDuration = 315576000000
+	maxTimestampSeconds  = 253402300799
+	minTimestampSeconds  = -62135596800
+)
+
+type marshalFunc func(encoder, protoreflect.Message) error
+
+// wellKnownTypeMarshaler returns a marshal function if the message type
+// has specialized serialization behavior. It returns nil otherwise.
+func wellKnownTypeMarshaler(name protoreflect.FullName) marshalFunc {
+	if name.Parent() != googleProtobufPackage {
+		return nil
+	}
+
+	switch name.Name() {
+	case "Any":
+		return encoder.marshalAny
+	case "Timestamp":
+		return encoder.marshalTimestamp
+	case "Duration":
+		return encoder.marshalDuration
+	case "BoolValue", "Int32Value", "Int64Value", "UInt32Value", "UInt64Value",
+		"FloatValue", "DoubleValue", "StringValue", "BytesValue":
+		return encoder.marshalWrapperType
+	case "Struct":
+		return encoder.marshalStruct
+	case "ListValue":
+		return encoder.marshalListValue
+	case "Value":
+		return encoder.marshalKnownValue
+	case "FieldMask":
+		return encoder.marshalFieldMask
+	case "Empty":
+		return encoder.marshalEmpty
+	}
+	return nil
+}
+
+// The JSON representation of an Any message uses the regular representation of
+// the deserialized, embedded message, with an additional field `@type` which
+// contains the type URL. If the embedded message type is well-known and has a
+// custom JSON representation, that representation will be embedded adding a
+// field `value` which holds the custom JSON in addition to the `@type` field.
+func (e encoder) marshalAny(m protoreflect.Message) error {
+	fds := m.Descriptor().Fields()
+	fdType := fds.ByNumber(anyTypeURLFieldNumber)
+	fdValue := fds.ByNumber(anyValueFieldNumber)
+
+	if !m.Has(fdType) {
+		if !m.Has(fdValue) {
+			// If message is empty, marshal out empty JSON object.
+			e.StartObject()
+			e.EndObject()
+			return nil
+		} else {
+			// Return error if type_url field is not set, but value is set.
+			return errors.New("proto: google.protobuf.Any: type_url is not set")
+		}
+	}
+
+	typeURL := m.Get(fdType).String()
+	valueVal := m.Get(fdValue)
+
+	emt, err := e.opts.Resolver.FindMessageByURL(typeURL)
+	if err != nil {
+		return fmt.Errorf("proto: google.protobuf.Any: unable to resolve %q: %v", typeURL, err)
+	}
+
+	em := emt.New()
+	err = proto.UnmarshalOptions{
+		AllowPartial: true, // never check required fields inside an Any
+		Resolver:     e.opts.Resolver,
+	}.Unmarshal(valueVal.Bytes(), em.Interface())
+	if err != nil {
+		return fmt.Errorf("proto: google.protobuf.Any: unable to unmarshal %q: %v", typeURL, err)
+	}
+
+	// If type of value has custom JSON encoding, marshal out a field "value"
+	// with corresponding custom JSON encoding of the embedded message as a
+	// field.
+	if marshal := wellKnownTypeMarshaler(emt.Descriptor().FullName()); marshal != nil {
+		// the embedded message is one level deeper, as in marshalMessage
+		if e.depth++; e.depth > e.opts.MaxDepth {
+			return &LimitError{Limit: LimitDepth, Max: e.opts.MaxDepth}
+		}
+
+		e.StartObject()
+		defer e.EndObject()
+
+		_ = e.WriteName(anyTypeFieldName)
+		if err := e.WriteString(typeURL); err != nil {
+			return err
+		}
+
+		_ = e.WriteName(anyValueFieldName)
+		return marshal(e, em)
+	}
+
+	// Else, marshal out the embedded message's fields in this Any object.
+	return e.marshalMessage(em, typeURL)
+}
+
+// Wrapper types are encoded as JSON primitives like string, number or boolean.
+func (e encoder) marshalWrapperType(m protoreflect.Message) error {
+	fd := m.Descriptor().Fields().ByNumber(wrapperValueFieldNumber)
+	val := m.Get(fd)
+	return e.marshalSingular(val, fd)
+}
+
+// The JSON representation for Empty is an empty JSON object.
+func (e encoder) marshalEmpty(protoreflect.Message) error {
+	e.StartObject()
+	e.EndObject()
+	return nil
+}
+
+// The JSON representation for Struct is a JSON object that contains the encoded
+// Struct.fields map and follows the serialization rules for a map.
+func (e encoder) marshalStruct(m protoreflect.Message) error {
+	fd := m.Descriptor().Fields().ByNumber(structFieldsFieldNumber)
+	return e.marshalMap(m.Get(fd).Map(), fd)
+}
+
+// The JSON representation for ListValue is JSON array that contains the encoded
+// ListValue.values repeated field and follows the serialization rules for a
+// repeated field.
+func (e encoder) marshalListValue(m protoreflect.Message) error {
+	fd := m.Descriptor().Fields().ByNumber(listValueValuesFieldNumber)
+	return e.marshalList(m.Get(fd).List(), fd)
+}
+
+// The JSON representation for a Value is dependent on the oneof field that is
+// set. Each of the field in the oneof has its own custom serialization rule. A
+// Value message needs to be a oneof field set, else it is an error.
+func (e encoder) marshalKnownValue(m protoreflect.Message) error {
+	od := m.Descriptor().Oneofs().ByName(valueKindOneofName)
+	fd := m.WhichOneof(od)
+	if fd == nil {
+		return errors.New("proto: google.protobuf.Value: none of the oneof fields is set")
+	}
+	if fd.Number() == valueNumberValueFieldNumber {
+		if v := m.Get(fd).Float(); math.IsNaN(v) || math.IsInf(v, 0) {
+			return fmt.Errorf("proto: google.protobuf.Value.number_value: invalid %v value", v)
+		}
+	}
+	return e.marshalSingular(m.Get(fd), fd)
+}
+
+// The JSON representation for a Duration is a JSON string that ends in the
+// suffix "s" (indicating seconds) and is preceded by the number of seconds,
+// with nanoseconds expressed as fractional seconds.
+//
+// Durations less than one second are represented with a 0 seconds field and a
+// positive or negative nanos field. For durations of one second or more, a
+// non-zero value for the nanos field must be of the same sign as the seconds
+// field.
+//
+// Duration.seconds must be from -315,576,000,000 to +315,576,000,000 inclusive.
+// Duration.nanos must be from -999,999,999 to +999,999,999 inclusive.
+func (e encoder) marshalDuration(m protoreflect.Message) error {
+	fds := m.Descriptor().Fields()
+	secs := m.Get(fds.ByNumber(secondsFieldNumber)).Int()
+	nanos := m.Get(fds.ByNumber(nanosFieldNumber)).Int()
+
+	if secs < -maxSecondsInDuration || secs > maxSecondsInDuration {
+		return fmt.Errorf("proto: google.protobuf.Duration: seconds out of range %v", secs)
+	}
+	if nanos < -secondsInNanos || nanos > secondsInNanos {
+		return fmt.Errorf("proto: google.protobuf.Duration: nanos out of range %v", nanos)
+	}
+	if (secs > 0 && nanos < 0) || (secs < 0 && nanos > 0) {
+		return errors.New("proto: google.protobuf.Duration: signs of seconds and nanos do not match")
+	}
+
+	// Generated output always contains 0, 3, 6, or 9 fractional digits,
+	// depending on required precision, followed by the suffix "s".
+	var sign string
+	if secs < 0 || nanos < 0 {
+		sign, secs, nanos = "-", -1*secs, -1*nanos
+	}
+	x := fmt.Sprintf("%s%d.%09d", sign, secs, nanos)
+	x = trimFraction(x)
+	return e.WriteString(x + "s")
+}
+
+// The JSON representation for a Timestamp is a JSON string in the RFC 3339
+// format, i.e. "{year}-{month}-{day}T{hour}:{min}:{sec}[.{frac_sec}]Z" where
+// {year} is always expressed using four digits while {month}, {day}, {hour},
+// {min}, and {sec} are zero-padded to two digits each. The fractional seconds,
+// which can go up to 9 digits, up to 1 nanosecond resolution, is optional. The
+// "Z" suffix indicates the timezone ("UTC"); the timezone is required.
+//
+// Timestamp.seconds must be from 0001-01-01T00:00:00Z to 9999-12-31T23:59:59Z
+// inclusive.
+// Timestamp.nanos must be from 0 to 999,999,999 inclusive.
+func (e encoder) marshalTimestamp(m protoreflect.Message) error {
+	fds := m.Descriptor().Fields()
+	secs := m.Get(fds.ByNumber(secondsFieldNumber)).Int()
+	nanos := m.Get(fds.ByNumber(nanosFieldNumber)).Int()
+
+	if secs < minTimestampSeconds || secs > maxTimestampSeconds {
+		return fmt.Errorf("proto: google.protobuf.Timestamp: seconds out of range %v", secs)
+	}
+	if nanos < 0 || nanos > secondsInNanos {
+		return fmt.Errorf("proto: google.protobuf.Timestamp: nanos out of range %v", nanos)
+	}
+
+	// Uses RFC 3339, where generated output will be Z-normalized and uses 0, 3,
+	// 6 or 9 fractional digits.
+	t := time.Unix(secs, nanos).UTC()
+	x := t.Format("2006-01-02T15:04:05.000000000")
+	x = trimFraction(x)
+	return e.WriteString(x + "Z")
+}
+
+// The JSON representation for a FieldMask is a JSON string where paths are
+// separated by a comma. Fields name in each path are converted to/from
+// lower-camel naming conventions. Encoding should fail if the path name would
+// end up differently after a round-trip.
+func (e encoder) marshalFieldMask(m protoreflect.Message) error {
+	fd := m.Descriptor().Fields().ByNumber(fieldMaskPathsFieldNumber)
+	list := m.Get(fd).List()
+	paths := make([]string, 0, list.Len())
+
+	for i := 0; i < list.Len(); i++ {
+		s := list.Get(i).String()
+		if !protoreflect.FullName(s).IsValid() {
+			return fmt.Errorf("proto: google.protobuf.FieldMask.paths contains invalid path: %q", s)
+		}
+		// Return error if conversion to camelCase is not reversible.
+		cc := jsonCamelCase(s)
+		if s != jsonSnakeCase(cc) {
+			return fmt.Errorf("proto: google.protobuf.FieldMask.paths contains irreversible value %q", s)
+		}
+		paths = append(paths, cc)
+	}
+
+	return e.WriteString(strings.Join(paths, ","))
+}
+
+// trimFraction trims the trailing zero groups of a 9 digits fraction so that
+// the output always contains 0, 3, 6 or 9 fractional digits.
+func trimFraction(x string) string {
+	x = strings.TrimSuffix(x, "000")
+	x = strings.TrimSuffix(x, "000")
+	x = strings.TrimSuffix(x, ".000")
+	return x
+}
+
+// jsonCamelCase converts a snake_case identifier to a camelCase identifier,
+// according to the protobuf JSON specification.
+func jsonCamelCase(s string) string {
+	var b []byte
+	var wasUnderscore bool
+	for i := 0; i < len(s); i++ { // proto identifiers are always ASCII
+		c := s[i]
+		if c != '_' {
+			if wasUnderscore && 'a' <= c && c <= 'z' {
+				c -= 'a' - 'A' // convert to uppercase
+			}
+			b = append(b, c)
+		}
+		wasUnderscore = c == '_'
+	}
+	return string(b)
+}
+
+// jsonSnakeCase converts a camelCase identifier to a snake_case identifier,
+// according to the protobuf JSON specification.
+func jsonSnakeCase(s string) string {
+	var b []byte
+	for i := 0; i < len(s); i++ { // proto identifiers are always ASCII
+		c := s[i]
+		if 'A' <= c && c <= 'Z' {
+			b = append(b, '_')
+			c += 'a' - 'A' // convert to lowercase
+		}
+		b = append(b, c)
+	}
+	return string(b)
+}
+
+type unmarshalFunc func(decoder, protoreflect.Message) error
+
+// wellKnownTypeUnmarshaler returns a unmarshal function if the message type
+// has specialized serialization behavior. It returns nil otherwise.
+func wellKnownTypeUnmarshaler(name protoreflect.FullName) unmarshalFunc {
+	if name.Parent() != googleProtobufPackage {
+		return nil
+	}
+
+	switch name.Name() {
+	case "Any":
+		return decoder.unmarshalAny
+	case "Timestamp":
+		return decoder.unmarshalTimestamp
+	case "Duration":
+		return decoder.unmarshalDuration
+	case "BoolValue", "Int32Value", "Int64Value", "UInt32Value", "UInt64Value",
+		"FloatValue", "DoubleValue", "StringValue", "BytesValue":
+		return decoder.unmarshalWrapperType
+	case "Struct":
+		return decoder.unmarshalStruct
+	case "ListValue":
+		return decoder.unmarshalListValue
+	case "Value":
+		return decoder.unmarshalKnownValue
+	case "FieldMask":
+		return decoder.unmarshalFieldMask
+	case "Empty":
+		return decoder.unmarshalEmpty
+	}
+	return nil
+}
+
+var (
+	errEmptyObject = errors.New(`empty object`)
+	errMissingType = errors.New(`missing "@type" field`)
+)
+
+// unmarshalAny expands the JSON object of a google.protobuf.Any. The "@type"
+// field is looked up first with a cloned Decoder, so the fields of the
+// embedded message may appear in any order.
+func (d decoder) unmarshalAny(m protoreflect.Message) error {
+	// Peek to check for ObjectOpen to avoid advancing a read.
+	start, err := d.Peek()
+	if err != nil {
+		return err
+	}
+	if start.Kind() != TokenObjectOpen {
+		return d.unexpectedTokenError(start)
+	}
+
+	// Use another decoder to parse the unread bytes for @type field. This
+	// avoids advancing a read from current decoder because the current JSON
+	// object may contain the fields of the embedded type.
+	dec := decoder{d.Clone(), UnmarshalOptions{}}
+	tok, err := findTypeURL(dec)
+	switch err {
+	case errEmptyObject:
+		// An empty JSON object translates to an empty Any message.
+		_, _ = d.Read() // Read ObjectOpen.
+		_, _ = d.Read() // Read ObjectClose.
+		return nil
+
+	case errMissingType:
+		if d.opts.DiscardUnknown {
+			// Treat all fields as unknowns, similar to an empty object.
+			return d.skipJSONValue()
+		}
+		// Use start.Pos() for line position.
+		return d.newError(start.Pos(), "%v", err)
+
+	default:
+		if err != nil {
+			return err
+		}
+	}
+
+	typeURL := tok.ParsedString()
+	emt, err := d.opts.Resolver.FindMessageByURL(typeURL)
+	if err != nil {
+		return d.newError(tok.Pos(), "unable to resolve %v: %q", tok.RawString(), err)
+	}
+
+	// Create new message for the embedded message type and unmarshal into it.
+	em := emt.New()
+	if unmarshal := wellKnownTypeUnmarshaler(emt.Descriptor().FullName()); unmarshal != nil {
+		// If embedded message is a custom type,
+		// unmarshal the JSON "value" field into it.
+		if err := d.unmarshalAnyValue(unmarshal, em); err != nil {
+			return err
+		}
+	} else {
+		// Else unmarshal the current JSON object into it.
+		if err := d.unmarshalMessage(em, true); err != nil {
+			return err
+		}
+	}
+	// Serialize the embedded message and assign the resulting bytes to the
+	// proto value field.
+	b, err := proto.MarshalOptions{
+		AllowPartial:  true, // No need to check required fields inside an Any.
+		Deterministic: true,
+	}.Marshal(em.Interface())
+	if err != nil {
+		return d.newError(start.Pos(), "error in marshaling Any.value field: %v", err)
+	}
+
+	fds := m.Descriptor().Fields()
+	m.Set(fds.ByNumber(anyTypeURLFieldNumber), protoreflect.ValueOfString(typeURL))
+	m.Set(fds.ByNumber(anyValueFieldNumber), protoreflect.ValueOfBytes(b))
+	return nil
+}
+
+// findTypeURL returns the token for the "@type" field value from the given
+// JSON bytes. It is expected that the given bytes start with ObjectOpen.
+// It returns errEmptyObject if the JSON object is empty or errMissingType if
+// @type field does not exist. It returns other error if the @type field is not
+// valid or other decoding issues.
+func findTypeURL(d decoder) (Token, error) {
+	var typeURL string
+	var typeTok Token
+	numFields := 0
+	// Skip start object.
+	_, _ = d.Read()
+
+	for {
+		tok, err := d.Read()
+		if err != nil {
+			return Token{}, err
+		}
+
+		switch tok.Kind() {
+		case TokenObjectClose:
+			if typeURL == "" {
+				// Did not find @type field.
+				if numFields > 0 {
+					return Token{}, errMissingType
+				}
+				return Token{}, errEmptyObject
+			}
+			return typeTok, nil
+
+		case TokenName:
+			numFields++
+			if tok.Name() != anyTypeFieldName {
+				// Skip value.
+				if err := d.skipJSONValue(); err != nil {
+					return Token{}, err
+				}
+				continue
+			}
+
+			// Return error if this was previously set already.
+			if typeURL != "" {
+				return Token{}, d.newError(tok.Pos(), `duplicate "@type" field`)
+			}
+			// Read field value.
+			tok, err := d.Read()
+			if err != nil {
+				return Token{}, err
+			}
+			if tok.Kind() != TokenString {
+				return Token{}, d.newError(tok.Pos(), `@type field value is not a string: %v`, tok.RawString())
+			}
+			typeURL = tok.ParsedString()
+			if typeURL == "" {
+				return Token{}, d.newError(tok.Pos(), `@type field contains empty value`)
+			}
+			typeTok = tok
+		}
+	}
+}
+
+// skipJSONValue parses a JSON value (null, boolean, string, number, object and
+// array) in order to advance the read to the next JSON value. It relies on
+// the Decoder returning an error if the types are not in valid sequence.
+// Nested objects and arrays are tracked with a counter instead of recursion,
+// so deeply nested unknown values cannot overflow the stack.
+func (d decoder) skipJSONValue() error {
+	var open int
+	for {
+		tok, err := d.Read()
+		if err != nil {
+			return err
+		}
+		switch tok.Kind() {
+		case TokenObjectOpen, TokenArrayOpen:
+			open++
+		case TokenObjectClose, TokenArrayClose:
+			open--
+		}
+		if open <= 0 {
+			return nil
+		}
+	}
+}
+
+// unmarshalAnyValue unmarshals the given custom-type message from the JSON
+// object's "value" field.
+func (d decoder) unmarshalAnyValue(unmarshal unmarshalFunc, m protoreflect.Message) error {
+	// Skip ObjectOpen, and start reading the fields.
+	_, _ = d.Read()
+
+	var found bool // Used for detecting duplicate "value".
+	for {
+		tok, err := d.Read()
+		if err != nil {
+			return err
+		}
+		switch tok.Kind() {
+		case TokenObjectClose:
+			if !found {
+				return d.newError(tok.Pos(), `missing "value" field`)
+			}
+			return nil
+
+		case TokenName:
+			switch tok.Name() {
+			case anyTypeFieldName:
+				// Skip the value as this was previously parsed already.
+				_, _ = d.Read()
+
+			case anyValueFieldName:
+				if found {
+					return d.newError(tok.Pos(), `duplicate "value" field`)
+				}
+				// Unmarshal the field value into the given message.
+				if err := unmarshal(d, m); err != nil {
+					return err
+				}
+				found = true
+
+			default:
+				if d.opts.DiscardUnknown {
+					if err := d.skipJSONValue(); err != nil {
+						return err
+					}
+					continue
+				}
+				return d.newError(tok.Pos(), "unknown field %v", tok.RawString())
+			}
+		}
+	}
+}
+
+func (d decoder) unmarshalWrapperType(m protoreflect.Message) error {
+	fd := m.Descriptor().Fields().ByNumber(wrapperValueFieldNumber)
+	val, err := d.unmarshalScalar(fd)
+	if err != nil {
+		return err
+	}
+	m.Set(fd, val)
+	return nil
+}
+
+func (d decoder) unmarshalEmpty(protoreflect.Message) error {
+	tok, err := d.Read()
+	if err != nil {
+		return err
+	}
+	if tok.Kind() != TokenObjectOpen {
+		return d.unexpectedTokenError(tok)
+	}
+
+	for {
+		tok, err := d.Read()
+		if err != nil {
+			return err
+		}
+		switch tok.Kind() {
+		case TokenObjectClose:
+			return nil
+
+		case TokenName:
+			if d.opts.DiscardUnknown {
+				if err := d.skipJSONValue(); err != nil {
+					return err
+				}
+				continue
+			}
+			return d.newError(tok.Pos(), "unknown field %v", tok.RawString())
+
+		default:
+			return d.unexpectedTokenError(tok)
+		}
+	}
+}
+
+func (d decoder) unmarshalStruct(m protoreflect.Message) error {
+	fd := m.Descriptor().Fields().ByNumber(structFieldsFieldNumber)
+	return d.unmarshalMap(m.Mutable(fd).Map(), fd)
+}
+
+func (d decoder) unmarshalListValue(m protoreflect.Message) error {
+	fd := m.Descriptor().Fields().ByNumber(listValueValuesFieldNumber)
+	return d.unmarshalList(m.Mutable(fd).List(), fd)
+}
+
+// unmarshalKnownValue picks the google.protobuf.Value oneof field from the
+// kind of the next JSON token.
+func (d decoder) unmarshalKnownValue(m protoreflect.Message) error {
+	tok, err := d.Peek()
+	if err != nil {
+		return err
+	}
+
+	fds := m.Descriptor().Fields()
+	var fd protoreflect.FieldDescriptor
+	var val protoreflect.Value
+	switch tok.Kind() {
+	case TokenNull:
+		_, _ = d.Read()
+		fd = fds.ByName("null_value")
+		val = protoreflect.ValueOfEnum(0)
+
+	case TokenBool:
+		tok, _ := d.Read()
+		fd = fds.ByName("bool_value")
+		val = protoreflect.ValueOfBool(tok.Bool())
+
+	case TokenNumber:
+		tok, _ := d.Read()
+		fd = fds.ByNumber(valueNumberValueFieldNumber)
+		var ok bool
+		val, ok = unmarshalFloat(tok, 64)
+		if !ok {
+			return d.newError(tok.Pos(), "invalid %v: %v", Value_message_fullname, tok.RawString())
+		}
+
+	case TokenString:
+		// A JSON string may have been encoded from the number_value field,
+		// e.g. "NaN", "Infinity", etc. There is no way to identify that and
+		// hence a JSON string is always assigned to the string_value field.
+		tok, _ := d.Read()
+		fd = fds.ByName("string_value")
+		val = protoreflect.ValueOfString(tok.ParsedString())
+
+	case TokenObjectOpen:
+		fd = fds.ByName("struct_value")
+		val = m.NewField(fd)
+		if err := d.unmarshalStruct(val.Message()); err != nil {
+			return err
+		}
+
+	case TokenArrayOpen:
+		fd = fds.ByName("list_value")
+		val = m.NewField(fd)
+		if err := d.unmarshalListValue(val.Message()); err != nil {
+			return err
+		}
+
+	default:
+		return d.newError(tok.Pos(), "invalid %v: %v", Value_message_fullname, tok.RawString())
+	}
+
+	m.Set(fd, val)
+	return nil
+}
+
+func (d decoder) unmarshalDuration(m protoreflect.Message) error {
+	tok, err := d.Read()
+	if err != nil {
+		return err
+	}
+	if tok.Kind() != TokenString {
+		return d.unexpectedTokenError(tok)
+	}
+
+	secs, nanos, ok := parseDuration(tok.ParsedString())
+	if !ok {
+		return d.newError(tok.Pos(), "invalid google.protobuf.Duration value %v", tok.RawString())
+	}
+	// Validate seconds. No need to validate nanos because parseDuration would
+	// have covered that already.
+	if secs < -maxSecondsInDuration || secs > maxSecondsInDuration {
+		return d.newError(tok.Pos(), "google.protobuf.Duration value out of range: %v", tok.RawString())
+	}
+
+	fds := m.Descriptor().Fields()
+	m.Set(fds.ByNumber(secondsFieldNumber), protoreflect.ValueOfInt64(secs))
+	m.Set(fds.ByNumber(nanosFieldNumber), protoreflect.ValueOfInt32(nanos))
+	return nil
+}
+
+// parseDuration parses the given input string for seconds and nanoseconds value
+// for the Duration JSON format. The format is a decimal number with a suffix
+// 's'. It can have optional plus/minus sign. There needs to be at least an
+// integer or fractional part. Fractional part is limited to 9 digits only for
+// nanoseconds precision, regardless of whether there are trailing zero digits.
+// Example values are 1s, 0.1s, 1.s, .1s, +1s, -1s, -.1s.
+func parseDuration(input string) (int64, int32, bool) {
+	b := []byte(input)
+	size := len(b)
+	if size < 2 {
+		return 0, 0, false
+	}
+	if b[size-1] != 's' {
+		return 0, 0, false
+	}
+	b = b[:size-1]
+
+	// Read optional plus/minus symbol.
+	var neg bool
+	switch b[0] {
+	case '-':
+		neg = true
+		b = b[1:]
+	case '+':
+		b = b[1:]
+	}
+	if len(b) == 0 {
+		return 0, 0, false
+	}
+
+	// Read the integer part.
+	var intp []byte
+	switch {
+	case b[0] == '0':
+		b = b[1:]
+
+	case '1' <= b[0] && b[0] <= '9':
+		intp = b[0:]
+		b = b[1:]
+		n := 1
+		for len(b) > 0 && '0' <= b[0] && b[0] <= '9' {
+			n++
+			b = b[1:]
+		}
+		intp = intp[:n]
+
+	case b[0] == '.':
+		// Continue below.
+
+	default:
+		return 0, 0, false
+	}
+
+	hasFrac := false
+	var frac [9]byte
+	if len(b) > 0 {
+		if b[0] != '.' {
+			return 0, 0, false
+		}
+		// Read the fractional part.
+		b = b[1:]
+		n := 0
+		for len(b) > 0 && n < 9 && '0' <= b[0] && b[0] <= '9' {
+			frac[n] = b[0]
+			n++
+			b = b[1:]
+		}
+		// It is not valid if there are more bytes left.
+		if len(b) > 0 {
+			return 0, 0, false
+		}
+		// Pad fractional part with 0s.
+		for i := n; i < 9; i++ {
+			frac[i] = '0'
+		}
+		hasFrac = true
+	}
+
+	var secs int64
+	if len(intp) > 0 {
+		var err error
+		secs, err = strconv.ParseInt(string(intp), 10, 64)
+		if err != nil {
+			return 0, 0, false
+		}
+	}
+
+	var nanos int64
+	if hasFrac {
+		nanob := strings.TrimLeft(string(frac[:]), "0")
+		if len(nanob) > 0 {
+			var err error
+			nanos, err = strconv.ParseInt(nanob, 10, 32)
+			if err != nil {
+				return 0, 0, false
+			}
+		}
+	}
+
+	if neg {
+		if secs > 0 {
+			secs = -secs
+		}
+		if nanos > 0 {
+			nanos = -nanos
+		}
+	}
+	return secs, int32(nanos), true
+}
+
+func (d decoder) unmarshalTimestamp(m protoreflect.Message) error {
+	tok, err := d.Read()
+	if err != nil {
+		return err
+	}
+	if tok.Kind() != TokenString {
+		return d.unexpectedTokenError(tok)
+	}
+
+	s := tok.ParsedString()
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return d.newError(tok.Pos(), "invalid google.protobuf.Timestamp value %v", tok.RawString())
+	}
+	// Validate seconds.
+	secs := t.Unix()
+	if secs < minTimestampSeconds || secs > maxTimestampSeconds {
+		return d.newError(tok.Pos(), "google.protobuf.Timestamp value out of range: %v", tok.RawString())
+	}
+	// Validate subseconds.
+	i := strings.LastIndexByte(s, '.')  // start of subsecond field
+	j := strings.LastIndexAny(s, "Z-+") // start of timezone field
+	if i >= 0 && j >= i && j-i > len(".999999999") {
+		return d.newError(tok.Pos(), "invalid google.protobuf.Timestamp value %v", tok.RawString())
+	}
+
+	fds := m.Descriptor().Fields()
+	m.Set(fds.ByNumber(secondsFieldNumber), protoreflect.ValueOfInt64(secs))
+	m.Set(fds.ByNumber(nanosFieldNumber), protoreflect.ValueOfInt32(int32(t.Nanosecond())))
+	return nil
+}
+
+func (d decoder) unmarshalFieldMask(m protoreflect.Message) error {
+	tok, err := d.Read()
+	if err != nil {
+		return err
+	}
+	if tok.Kind() != TokenString {
+		return d.unexpectedTokenError(tok)
+	}
+	str := strings.TrimSpace(tok.ParsedString())
+	if str == "" {
+		return nil
+	}
+	paths := strings.Split(str, ",")
+
+	fd := m.Descriptor().Fields().ByNumber(fieldMaskPathsFieldNumber)
+	list := m.Mutable(fd).List()
+
+	for _, s0 := range paths {
+		s := jsonSnakeCase(s0)
+		if strings.Contains(s0, "_") || !protoreflect.FullName(s).IsValid() {
+			return d.newError(tok.Pos(), "google.protobuf.FieldMask.paths contains invalid path: %q", s0)
+		}
+		list.Append(protoreflect.ValueOfString(s))
+	}
+	return nil
+}
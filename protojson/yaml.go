@@ -0,0 +1,247 @@
+package protojson
+
+import (
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// yamlIndent is the indentation of nested YAML mappings and sequences.
+const yamlIndent = "  "
+
+// MarshalYAML writes the given proto.Message in YAML format using default
+// options.
+func MarshalYAML(m proto.Message) ([]byte, error) {
+	return MarshalOptions{}.MarshalYAML(m)
+}
+
+// MarshalYAML writes the given proto.Message in block style YAML using options
+// in MarshalOptions. The YAML document holds exactly the JSON value produced
+// by Marshal with the same options, so field names, Any expansion, redaction,
+// field order and the other options apply alike, while Multiline and Indent
+// are ignored. Strings are always double-quoted so that standard YAML parsers
+// never mistake them, e.g. the 64-bit integers, for other types.
+func (o MarshalOptions) MarshalYAML(m proto.Message) ([]byte, error) {
+	o.Multiline = false
+	o.Indent = ""
+	b, err := o.marshal(m)
+	if err != nil {
+		return nil, err
+	}
+
+	y := yamlEncoder{dec: NewDecoder(b)}
+	if err := y.encode(); err != nil {
+		return nil, err
+	}
+	return y.out, nil
+}
+
+// yamlEncoder converts the JSON tokens read from dec to block style YAML.
+type yamlEncoder struct {
+	dec *Decoder
+	out []byte
+}
+
+func (y *yamlEncoder) encode() error {
+	tok, err := y.dec.Read()
+	if err != nil {
+		return err
+	}
+	if tok.Kind() == TokenObjectOpen {
+		if next, err := y.dec.Peek(); err != nil {
+			return err
+		} else if next.Kind() != TokenObjectClose {
+			return y.writeMapping("", false)
+		}
+	}
+
+	// empty messages and the plain values of well-known types
+	if err := y.writeValue(tok, ""); err != nil {
+		return err
+	}
+	y.out = y.out[1:]
+	return nil
+}
+
+// writeMapping writes the object entries up to the closing token, each at the
+// given indentation. If inline, the first entry continues the current line.
+func (y *yamlEncoder) writeMapping(indent string, inline bool) error {
+	for {
+		tok, err := y.dec.Read()
+		if err != nil {
+			return err
+		}
+		if tok.Kind() == TokenObjectClose {
+			return nil
+		}
+
+		if !inline {
+			y.out = append(y.out, indent...)
+		}
+		inline = false
+		y.out = appendYAMLKey(y.out, tok.Name())
+		y.out = append(y.out, ':')
+
+		tok, err = y.dec.Read()
+		if err != nil {
+			return err
+		}
+		if err := y.writeValue(tok, indent+yamlIndent); err != nil {
+			return err
+		}
+	}
+}
+
+// writeSequence writes the array elements up to the closing token, each at the
+// given indentation.
+func (y *yamlEncoder) writeSequence(indent string) error {
+	for {
+		tok, err := y.dec.Read()
+		if err != nil {
+			return err
+		}
+		if tok.Kind() == TokenArrayClose {
+			return nil
+		}
+
+		y.out = append(y.out, indent...)
+		y.out = append(y.out, '-')
+		if tok.Kind() == TokenObjectOpen {
+			if next, err := y.dec.Peek(); err != nil {
+				return err
+			} else if next.Kind() != TokenObjectClose {
+				// "- name: value" with the other entries aligned to name
+				y.out = append(y.out, ' ')
+				if err := y.writeMapping(indent+yamlIndent, true); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+		if err := y.writeValue(tok, indent+yamlIndent); err != nil {
+			return err
+		}
+	}
+}
+
+// writeValue writes the value starting with tok after a mapping key or a
+// sequence dash, nested collections are written at the given indentation.
+func (y *yamlEncoder) writeValue(tok Token, indent string) error {
+	switch tok.Kind() {
+	case TokenNull:
+		y.out = append(y.out, " null\n"...)
+	case TokenBool:
+		y.out = append(y.out, ' ')
+		y.out = strconv.AppendBool(y.out, tok.Bool())
+		y.out = append(y.out, '\n')
+	case TokenNumber:
+		y.out = append(y.out, ' ')
+		y.out = appendYAMLNumber(y.out, tok.RawString())
+		y.out = append(y.out, '\n')
+	case TokenString:
+		y.out = append(y.out, ' ')
+		y.out = appendYAMLString(y.out, tok.ParsedString())
+		y.out = append(y.out, '\n')
+	case TokenObjectOpen:
+		if next, err := y.dec.Peek(); err != nil {
+			return err
+		} else if next.Kind() == TokenObjectClose {
+			_, _ = y.dec.Read()
+			y.out = append(y.out, " {}\n"...)
+			return nil
+		}
+		y.out = append(y.out, '\n')
+		return y.writeMapping(indent, false)
+	case TokenArrayOpen:
+		if next, err := y.dec.Peek(); err != nil {
+			return err
+		} else if next.Kind() == TokenArrayClose {
+			_, _ = y.dec.Read()
+			y.out = append(y.out, " []\n"...)
+			return nil
+		}
+		y.out = append(y.out, '\n')
+		return y.writeSequence(indent)
+	}
+	return nil
+}
+
+// appendYAMLNumber appends the JSON number as a plain YAML scalar. YAML 1.1
+// only reads an exponent form as a float if it has a decimal point and a
+// signed exponent, so 1e+21 is written as 1.0e+21.
+func appendYAMLNumber(out []byte, num string) []byte {
+	i := strings.IndexAny(num, "eE")
+	if i < 0 {
+		return append(out, num...)
+	}
+	mantissa, exp := num[:i], num[i+1:]
+	out = append(out, mantissa...)
+	if !strings.Contains(mantissa, ".") {
+		out = append(out, ".0"...)
+	}
+	out = append(out, 'e')
+	if exp[0] != '+' && exp[0] != '-' {
+		out = append(out, '+')
+	}
+	return append(out, exp...)
+}
+
+// appendYAMLKey appends the mapping key, plain if it cannot be read as
+// anything but a string and double-quoted otherwise.
+func appendYAMLKey(out []byte, key string) []byte {
+	if isPlainYAMLKey(key) {
+		return append(out, key...)
+	}
+	return appendYAMLString(out, key)
+}
+
+func isPlainYAMLKey(key string) bool {
+	if key == "" {
+		return false
+	}
+	for i := 0; i < len(key); i++ {
+		c := key[i]
+		switch {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', c == '_':
+		case i > 0 && ('0' <= c && c <= '9' || c == '-' || c == '.'):
+		default:
+			return false
+		}
+	}
+	// YAML 1.1 reads these words as booleans or null
+	switch strings.ToLower(key) {
+	case "true", "false", "yes", "no", "on", "off", "y", "n", "null":
+		return false
+	}
+	return true
+}
+
+// appendYAMLString appends s as a double-quoted YAML scalar. Besides the quote
+// and the backslash, the characters that are not printable in YAML or that
+// YAML treats as line breaks are escaped.
+func appendYAMLString(out []byte, s string) []byte {
+	const hex = "0123456789abcdef"
+	out = append(out, '"')
+	for _, r := range s {
+		switch {
+		case r == '"' || r == '\\':
+			out = append(out, '\\', byte(r))
+		case r == '\n':
+			out = append(out, '\\', 'n')
+		case r == '\t':
+			out = append(out, '\\', 't')
+		case r == '\r':
+			out = append(out, '\\', 'r')
+		case r < 0x20 || r == 0x7f:
+			out = append(out, '\\', 'x', hex[r>>4], hex[r&0xf])
+		case r == 0x85 || r == 0x2028 || r == 0x2029 || r == 0xfeff ||
+			0x80 <= r && r < 0xa0 || 0xd800 <= r && r < 0xe000 || r == 0xfffe || r == 0xffff:
+			out = append(out, '\\', 'u', hex[r>>12], hex[r>>8&0xf], hex[r>>4&0xf], hex[r&0xf])
+		default:
+			out = utf8.AppendRune(out, r)
+		}
+	}
+	return append(out, '"')
+}
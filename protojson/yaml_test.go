@@ -0,0 +1,115 @@
+package protojson
+
+import (
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestMarshalYAML(t *testing.T) {
+	hello := newTextHelloRequest(t)
+	list, err := structpb.NewList([]interface{}{1, "a", []interface{}{}, map[string]interface{}{"x": []interface{}{true, map[string]interface{}{}}}, []interface{}{nil, 2}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		opts   MarshalOptions
+		m      proto.Message
+		expect string
+	}{
+		{"default", MarshalOptions{Multiline: true}, hello, `success: true
+score: 1.5
+age: 3
+timestamp: "9"
+data: "YQD/"
+tags:
+  - "x"
+  - "y\n\"z"
+labels:
+  b: "2"
+  "true": "1"
+any:
+  "@type": "type.googleapis.com/Person"
+  name: "bob"
+  like: "book"
+  age: 18
+`},
+		{"redact", MarshalOptions{Redact: &Redaction{}, UseProtoNames: true, FieldMask: &fieldmaskpb.FieldMask{Paths: []string{"any"}}}, hello, `any:
+  "@type": "type.googleapis.com/Person"
+  name: "***"
+  like: "book"
+  age: 18
+`},
+		{"list", MarshalOptions{}, list, `- 1
+- "a"
+- []
+- x:
+    - true
+    - {}
+-
+  - null
+  - 2
+`},
+		{"empty", MarshalOptions{}, &HelloRequest{}, "{}\n"},
+		{"float exponent", MarshalOptions{}, structpb.NewListValue(&structpb.ListValue{Values: []*structpb.Value{structpb.NewNumberValue(1e21), structpb.NewNumberValue(-1e-7), structpb.NewNumberValue(1.5e21)}}), "- 1.0e+21\n- -1.0e-7\n- 1.5e+21\n"},
+		{"well-known type", MarshalOptions{}, &timestamppb.Timestamp{Seconds: 1}, "\"1970-01-01T00:00:01Z\"\n"},
+		{"escape", MarshalOptions{}, &Person{Name: "a\x01\u2028é", Like: "#yes: no"}, "name: \"a\\x01\\u2028é\"\nlike: \"#yes: no\"\n"},
+	}
+	for _, test := range tests {
+		b, err := test.opts.MarshalYAML(test.m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != test.expect {
+			t.Errorf("%s: expect %s, but got %s", test.name, test.expect, b)
+		}
+	}
+}
+
+func TestAppendYAMLNumber(t *testing.T) {
+	tests := []struct {
+		num    string
+		expect string
+	}{
+		{"0", "0"},
+		{"-12", "-12"},
+		{"1.5", "1.5"},
+		{"1e+21", "1.0e+21"},
+		{"-1e-07", "-1.0e-07"},
+		{"1.5e+21", "1.5e+21"},
+		{"2E5", "2.0e+5"},
+	}
+	for _, test := range tests {
+		if got := string(appendYAMLNumber(nil, test.num)); got != test.expect {
+			t.Errorf("%s: expect %s, but got %s", test.num, test.expect, got)
+		}
+	}
+}
+
+func TestIsPlainYAMLKey(t *testing.T) {
+	tests := []struct {
+		key    string
+		expect bool
+	}{
+		{"name", true},
+		{"user_name", true},
+		{"a.b-c1", true},
+		{"", false},
+		{"1a", false},
+		{"@type", false},
+		{"[ext.name]", false},
+		{"Yes", false},
+		{"null", false},
+		{"a b", false},
+	}
+	for _, test := range tests {
+		if got := isPlainYAMLKey(test.key); got != test.expect {
+			t.Errorf("%q: expect %v, but got %v", test.key, test.expect, got)
+		}
+	}
+}
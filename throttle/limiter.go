@@ -0,0 +1,35 @@
+package throttle
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var errLimit = errors.New("limit and period must be > 0, n must be >= 0")
+
+// Result 限流结果
+type Result struct {
+	Allowed    bool
+	Remaining  int           // 当前剩余可用配额
+	ResetAt    time.Time     // 配额完全恢复的时间
+	RetryAfter time.Duration // 被拒绝时需要等待的时间，负数表示永远无法满足
+}
+
+// Limiter 在 period 内最多允许 limit 次请求，n 为本次请求消耗的配额
+type Limiter interface {
+	Allow(ctx context.Context, key string, limit int, period time.Duration, n int) (Result, error)
+}
+
+// MemLimiter 内存限流器，Close 停止后台过期清理
+type MemLimiter interface {
+	Limiter
+	Close() error
+}
+
+func checkLimit(limit int, period time.Duration, n int) error {
+	if limit <= 0 || period <= 0 || n < 0 {
+		return errLimit
+	}
+	return nil
+}
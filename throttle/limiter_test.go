@@ -0,0 +1,215 @@
+package throttle
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+func initRedisClient() redis.UniversalClient {
+	return redis.NewUniversalClient(&redis.UniversalOptions{
+		Addrs: []string{"127.0.0.1:6379"},
+	})
+}
+
+func TestLimiter_Allow(t *testing.T) {
+	rds := initRedisClient()
+	mems := []MemLimiter{
+		NewMemFixedWindowLimiter(10, time.Second),
+		NewMemSlidingLogLimiter(10, time.Second),
+		NewMemGCRALimiter(10, time.Second),
+	}
+	for _, m := range mems {
+		defer m.Close()
+	}
+
+	limiters := []struct {
+		name    string
+		limiter Limiter
+	}{
+		{"mem_fixed_window", mems[0]},
+		{"mem_sliding_log", mems[1]},
+		{"mem_gcra", mems[2]},
+		{"redis_fixed_window", NewRedisFixedWindowLimiter(rds)},
+		{"redis_sliding_log", NewRedisSlidingLogLimiter(rds)},
+		{"redis_gcra", NewRedisGCRALimiter(rds)},
+	}
+
+	for _, l := range limiters {
+		ll := l
+		t.Run(l.name, func(t *testing.T) {
+			ctx := context.Background()
+			key := "testLimiter:" + ll.name
+			// 固定窗口按 period 对齐，使用较长的 period 避免测试跨越窗口
+			period := time.Hour
+
+			for i := 2; i >= 0; i-- {
+				r, err := ll.limiter.Allow(ctx, key, 3, period, 1)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !r.Allowed || r.Remaining != i || r.RetryAfter != 0 {
+					t.Errorf("expect allowed with remaining %d, but got %+v", i, r)
+				}
+				if !r.ResetAt.After(time.Now()) || r.ResetAt.After(time.Now().Add(period)) {
+					t.Errorf("expect reset within %s, but got %s", period, r.ResetAt)
+				}
+			}
+
+			r, err := ll.limiter.Allow(ctx, key, 3, period, 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if r.Allowed || r.Remaining != 0 || r.RetryAfter <= 0 || r.RetryAfter > period {
+				t.Errorf("expect denied with retry after, but got %+v", r)
+			}
+
+			r, err = ll.limiter.Allow(ctx, key+":n", 3, period, 4)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if r.Allowed || r.Remaining != 3 || r.RetryAfter != -1 {
+				t.Errorf("expect denied forever, but got %+v", r)
+			}
+
+			_, err = ll.limiter.Allow(ctx, key, 0, period, 1)
+			if err != errLimit {
+				t.Errorf("expect %v, but got %v", errLimit, err)
+			}
+		})
+	}
+}
+
+func TestLimiter_Algorithm(t *testing.T) {
+	type step struct {
+		dt        int64
+		n         int
+		allowed   bool
+		remaining int
+		reset     int64
+		retry     int64
+	}
+
+	tests := []struct {
+		name  string
+		allow func(e *limitEntry, now, period int64, limit, n int) Result
+		steps []step
+	}{
+		{"fixed_window", fixedWindow, []step{
+			{0, 1, true, 2, 900, 0},
+			{100, 2, true, 0, 800, 0},
+			{100, 1, false, 0, 700, 700},
+			{700, 3, true, 0, 1000, 0},
+			{0, 4, false, 0, 1000, -1},
+		}},
+		{"sliding_log", slidingLog, []step{
+			{0, 1, true, 2, 1000, 0},
+			{100, 2, true, 0, 1000, 0},
+			{100, 1, false, 0, 900, 800},
+			{800, 1, true, 0, 1000, 0},
+			{0, 2, false, 0, 1000, 100},
+			{100, 0, true, 2, 900, 0},
+		}},
+		{"gcra", gcra, []step{
+			{0, 1, true, 2, 333, 0},
+			{100, 2, true, 0, 899, 0},
+			{100, 1, false, 0, 799, 133},
+			{133, 1, true, 0, 999, 0},
+			{0, 4, false, 0, 999, -1},
+			{1000, 3, true, 0, 1000, 0},
+		}},
+	}
+
+	for _, test := range tests {
+		e := &limitEntry{}
+		now := int64(10100)
+		for i, s := range test.steps {
+			now += s.dt
+			r := test.allow(e, now, 1000, 3, s.n)
+			if r.Allowed != s.allowed || r.Remaining != s.remaining {
+				t.Errorf("%s %d: expect allowed=%v remaining=%d, but got %+v", test.name, i, s.allowed, s.remaining, r)
+			}
+			if reset := r.ResetAt.UnixNano() - now; reset != s.reset {
+				t.Errorf("%s %d: expect reset %d, but got %d", test.name, i, s.reset, reset)
+			}
+			if int64(r.RetryAfter) != s.retry {
+				t.Errorf("%s %d: expect retry %d, but got %d", test.name, i, s.retry, r.RetryAfter)
+			}
+		}
+	}
+}
+
+func TestMemLimiter_Close(t *testing.T) {
+	before := runtime.NumGoroutine()
+	limiter := NewMemGCRALimiter(10, time.Millisecond)
+	if err := limiter.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := limiter.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > before && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if n := runtime.NumGoroutine(); n > before {
+		t.Errorf("expect %d goroutines, but got %d", before, n)
+	}
+}
+
+func TestMemLimiter_Expire(t *testing.T) {
+	limiter := NewMemSlidingLogLimiter(10, 5*time.Millisecond)
+	defer limiter.Close()
+
+	_, _ = limiter.Allow(context.Background(), "k", 3, time.Millisecond, 1)
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		m := limiter.(*memLimiter)
+		m.mux.Lock()
+		n := len(m.entries)
+		m.mux.Unlock()
+		if n == 0 {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Error("expect expired entry to be removed")
+}
+
+func TestMemLimiter_MaxEntries(t *testing.T) {
+	limiters := []MemLimiter{
+		NewMemFixedWindowLimiterWithLimit(10, 0, 3),
+		NewMemSlidingLogLimiterWithLimit(10, 0, 3),
+		NewMemGCRALimiterWithLimit(10, 0, 3),
+	}
+
+	ctx := context.Background()
+	for _, limiter := range limiters {
+		m := limiter.(*memLimiter)
+		for i := 0; i < 100; i++ {
+			if _, err := limiter.Allow(ctx, fmt.Sprintf("k%d", i), 3, time.Second, 1); err != nil {
+				t.Fatal(err)
+			}
+			if n := len(m.entries); n > 3 {
+				t.Fatalf("expect at most 3, but got %d", n)
+			}
+		}
+
+		r, _ := limiter.Allow(ctx, "k99", 3, time.Second, 1)
+		if !r.Allowed || r.Remaining != 1 {
+			t.Errorf("expect remaining 1, but got %+v", r)
+		}
+
+		// 新 key 被拒绝时不保存
+		r, _ = limiter.Allow(ctx, "denied", 3, time.Second, 4)
+		if _, ok := m.entries["denied"]; r.Allowed || ok {
+			t.Errorf("expect denied key not stored, but got %+v", r)
+		}
+		_ = limiter.Close()
+	}
+}
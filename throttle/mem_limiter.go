@@ -0,0 +1,239 @@
+package throttle
+
+import (
+	"context"
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+type memLimiter struct {
+	entries    map[string]*limitEntry
+	mux        sync.Mutex
+	maxEntries int
+	done       chan struct{}
+	closeOnce  sync.Once
+	allow      func(e *limitEntry, now, period int64, limit, n int) Result
+}
+
+type limitEntry struct {
+	start int64   // 固定窗口的开始时间
+	count int     // 固定窗口内已使用的配额
+	log   []int64 // 滑动日志中的请求时间
+	tat   int64   // GCRA 理论到达时间
+	expAt int64
+}
+
+// NewMemFixedWindowLimiter 固定窗口，窗口按 period 对齐，窗口切换时配额重置
+func NewMemFixedWindowLimiter(cap int, checkExpInterval time.Duration) MemLimiter {
+	return NewMemFixedWindowLimiterWithLimit(cap, checkExpInterval, 0)
+}
+
+// NewMemFixedWindowLimiterWithLimit maxEntries 含义同 NewMemThrottlerWithLimit
+func NewMemFixedWindowLimiterWithLimit(cap int, checkExpInterval time.Duration, maxEntries int) MemLimiter {
+	return newMemLimiter(cap, checkExpInterval, maxEntries, fixedWindow)
+}
+
+// NewMemSlidingLogLimiter 滑动日志，记录每次请求时间，任意 period 长度的区间内不超过 limit
+func NewMemSlidingLogLimiter(cap int, checkExpInterval time.Duration) MemLimiter {
+	return NewMemSlidingLogLimiterWithLimit(cap, checkExpInterval, 0)
+}
+
+// NewMemSlidingLogLimiterWithLimit maxEntries 含义同 NewMemThrottlerWithLimit
+func NewMemSlidingLogLimiterWithLimit(cap int, checkExpInterval time.Duration, maxEntries int) MemLimiter {
+	return newMemLimiter(cap, checkExpInterval, maxEntries, slidingLog)
+}
+
+// NewMemGCRALimiter GCRA，请求按 period/limit 的间隔平滑放行，最多允许 limit 的突发
+func NewMemGCRALimiter(cap int, checkExpInterval time.Duration) MemLimiter {
+	return NewMemGCRALimiterWithLimit(cap, checkExpInterval, 0)
+}
+
+// NewMemGCRALimiterWithLimit maxEntries 含义同 NewMemThrottlerWithLimit
+func NewMemGCRALimiterWithLimit(cap int, checkExpInterval time.Duration, maxEntries int) MemLimiter {
+	return newMemLimiter(cap, checkExpInterval, maxEntries, gcra)
+}
+
+// newMemLimiter maxEntries 限制 key 总数，达到上限时随机采样淘汰配额已恢复或最早恢复的 key，
+// 被淘汰的 key 下次请求时按配额全满处理。不大于 0 时不限制
+func newMemLimiter(cap int, checkExpInterval time.Duration, maxEntries int, allow func(e *limitEntry, now, period int64, limit, n int) Result) MemLimiter {
+	m := &memLimiter{
+		entries:    make(map[string]*limitEntry, cap),
+		maxEntries: maxEntries,
+		done:       make(chan struct{}),
+		allow:      allow,
+	}
+
+	if checkExpInterval > 0 {
+		go func() {
+			ticker := time.NewTicker(checkExpInterval)
+			defer ticker.Stop()
+
+			for {
+				select {
+				case now := <-ticker.C:
+					timestamp := now.UnixNano()
+					m.mux.Lock()
+					for k, v := range m.entries {
+						if v.expAt < timestamp {
+							delete(m.entries, k)
+						}
+					}
+					m.mux.Unlock()
+				case <-m.done:
+					return
+				}
+			}
+		}()
+	}
+
+	return m
+}
+
+func (m *memLimiter) Allow(ctx context.Context, key string, limit int, period time.Duration, n int) (Result, error) {
+	if err := checkLimit(limit, period, n); err != nil {
+		return Result{}, err
+	}
+
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	now := time.Now().UnixNano()
+	v, ok := m.entries[key]
+	if ok {
+		return m.allow(v, now, int64(period), limit, n), nil
+	}
+
+	// 新 key 被拒绝时状态未变化，不保存
+	v = &limitEntry{}
+	r := m.allow(v, now, int64(period), limit, n)
+	if r.Allowed {
+		m.evict(now)
+		m.entries[key] = v
+	}
+	return r, nil
+}
+
+// Close 停止后台过期清理，可重复调用
+func (m *memLimiter) Close() error {
+	m.closeOnce.Do(func() {
+		close(m.done)
+	})
+	return nil
+}
+
+// evict 在 key 数达到上限时随机采样，淘汰配额已恢复或最早恢复的一个
+func (m *memLimiter) evict(timestamp int64) {
+	if m.maxEntries <= 0 || len(m.entries) < m.maxEntries {
+		return
+	}
+
+	var (
+		victim string
+		expAt  int64
+		n      int
+	)
+	for k, v := range m.entries {
+		if v.expAt < timestamp {
+			delete(m.entries, k)
+			return
+		}
+		if n == 0 || v.expAt < expAt {
+			victim, expAt = k, v.expAt
+		}
+		n++
+		if n >= evictSamples {
+			break
+		}
+	}
+	delete(m.entries, victim)
+}
+
+func fixedWindow(e *limitEntry, now, period int64, limit, n int) Result {
+	start := now - now%period
+	if e.start != start {
+		e.start = start
+		e.count = 0
+	}
+	end := start + period
+	e.expAt = end
+
+	r := Result{Remaining: limit - e.count, ResetAt: time.Unix(0, end)}
+	if n > limit {
+		r.RetryAfter = -1
+		return r
+	}
+	if e.count+n > limit {
+		r.RetryAfter = time.Duration(end - now)
+		return r
+	}
+
+	e.count += n
+	r.Allowed = true
+	r.Remaining = limit - e.count
+	return r
+}
+
+func slidingLog(e *limitEntry, now, period int64, limit, n int) Result {
+	// 请求时间不晚于 now-period 的记录已移出窗口
+	cut := now - period
+	if i := sort.Search(len(e.log), func(i int) bool { return e.log[i] > cut }); i > 0 {
+		e.log = append(e.log[:0], e.log[i:]...)
+	}
+	count := len(e.log)
+
+	r := Result{Remaining: limit - count, ResetAt: time.Unix(0, now)}
+	if count > 0 {
+		r.ResetAt = time.Unix(0, e.log[count-1]+period)
+	}
+	if n > limit {
+		r.RetryAfter = -1
+		return r
+	}
+	if count+n > limit {
+		r.RetryAfter = time.Duration(e.log[count+n-limit-1] + period - now)
+		return r
+	}
+
+	for i := 0; i < n; i++ {
+		e.log = append(e.log, now)
+	}
+	if n > 0 {
+		r.ResetAt = time.Unix(0, now+period)
+	}
+	e.expAt = r.ResetAt.UnixNano()
+	r.Allowed = true
+	r.Remaining = limit - len(e.log)
+	return r
+}
+
+func gcra(e *limitEntry, now, period int64, limit, n int) Result {
+	tat := e.tat
+	if tat < now {
+		tat = now
+	}
+
+	// d 为当前还可突发的时长，比较与取整都先乘 limit，避免 period/limit 的浮点误差
+	d := period - (tat - now)
+	r := Result{
+		Remaining: int(math.Floor(float64(d) * float64(limit) / float64(period))),
+		ResetAt:   time.Unix(0, tat),
+	}
+	if n > limit {
+		r.RetryAfter = -1
+		return r
+	}
+
+	if w := float64(n)*float64(period) - float64(d)*float64(limit); w > 0 {
+		r.RetryAfter = time.Duration(math.Ceil(w / float64(limit)))
+		return r
+	}
+
+	e.tat = tat + int64(float64(n)*float64(period)/float64(limit))
+	e.expAt = e.tat
+	r.Allowed = true
+	r.Remaining = int(math.Floor(float64(period-(e.tat-now)) * float64(limit) / float64(period)))
+	r.ResetAt = time.Unix(0, e.tat)
+	return r
+}
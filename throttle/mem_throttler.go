@@ -7,9 +7,21 @@ import (
 	"time"
 )
 
+// evictSamples 淘汰时随机采样的 entry 数
+const evictSamples = 5
+
+// MemThrottler 内存限流器，Close 停止后台过期清理
+type MemThrottler interface {
+	TokenThrottler
+	Close() error
+}
+
 type memThrottler struct {
-	entries map[string]*entry
-	mux     sync.Mutex
+	entries    map[string]*entry
+	mux        sync.Mutex
+	maxEntries int
+	done       chan struct{}
+	closeOnce  sync.Once
 }
 
 type entry struct {
@@ -18,14 +30,23 @@ type entry struct {
 	expAt int64
 }
 
-func NewMemThrottler(cap int, checkExpInterval time.Duration) TokenThrottler {
+func NewMemThrottler(cap int, checkExpInterval time.Duration) MemThrottler {
+	return NewMemThrottlerWithLimit(cap, checkExpInterval, 0)
+}
+
+// NewMemThrottlerWithLimit maxEntries 限制 key 总数，达到上限时随机采样淘汰令牌已恢复或最早恢复的 key，
+// 被淘汰的 key 下次请求时按满桶处理。不大于 0 时不限制
+func NewMemThrottlerWithLimit(cap int, checkExpInterval time.Duration, maxEntries int) MemThrottler {
 	m := &memThrottler{
-		entries: make(map[string]*entry, cap),
+		entries:    make(map[string]*entry, cap),
+		maxEntries: maxEntries,
+		done:       make(chan struct{}),
 	}
 
 	if checkExpInterval > 0 {
 		go func() {
 			ticker := time.NewTicker(checkExpInterval)
+			defer ticker.Stop()
 
 			for {
 				select {
@@ -38,6 +59,8 @@ func NewMemThrottler(cap int, checkExpInterval time.Duration) TokenThrottler {
 						}
 					}
 					m.mux.Unlock()
+				case <-m.done:
+					return
 				}
 			}
 		}()
@@ -71,6 +94,7 @@ func (m *memThrottler) Throttle(
 
 	v, ok := m.entries[key]
 	if !ok {
+		m.evict(timestamp)
 		rest := quota - acquire
 		m.entries[key] = &entry{
 			rest:  rest,
@@ -80,19 +104,55 @@ func (m *memThrottler) Throttle(
 		return false, rest, 0, nil
 	}
 
-	v.rest = int(math.Floor(float64(timestamp-v.last)*speed)) + v.rest
-	if v.rest < acquire {
-		wait := int64(math.Ceil(float64(acquire-v.rest) / speed))
-		return true, v.rest, time.Duration(wait), nil
+	// 拒绝时不更新令牌桶，避免下次请求重复计算已恢复的令牌
+	rest := int(math.Floor(float64(timestamp-v.last)*speed)) + v.rest
+	if rest < acquire {
+		wait := int64(math.Ceil(float64(acquire-v.rest)/speed)) - (timestamp - v.last)
+		return true, rest, time.Duration(wait), nil
 	}
 
-	if v.rest > quota {
-		v.rest = quota
+	if rest > quota {
+		rest = quota
 	}
-	v.rest -= acquire
+	v.rest = rest - acquire
 	v.last = timestamp
 
 	restore := quota - v.rest
 	v.expAt = timestamp + int64(math.Ceil(float64(restore)/speed))
 	return false, v.rest, 0, nil
 }
+
+// Close 停止后台过期清理，可重复调用
+func (m *memThrottler) Close() error {
+	m.closeOnce.Do(func() {
+		close(m.done)
+	})
+	return nil
+}
+
+// evict 在 key 数达到上限时随机采样，淘汰令牌已恢复或最早恢复的一个
+func (m *memThrottler) evict(timestamp int64) {
+	if m.maxEntries <= 0 || len(m.entries) < m.maxEntries {
+		return
+	}
+
+	var (
+		victim string
+		expAt  int64
+		n      int
+	)
+	for k, v := range m.entries {
+		if v.expAt < timestamp {
+			delete(m.entries, k)
+			return
+		}
+		if n == 0 || v.expAt < expAt {
+			victim, expAt = k, v.expAt
+		}
+		n++
+		if n >= evictSamples {
+			break
+		}
+	}
+	delete(m.entries, victim)
+}
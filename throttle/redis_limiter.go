@@ -0,0 +1,95 @@
+package throttle
+
+import (
+	"context"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// 以下脚本的入参均为：1 limit  2 period(微秒)  3 n
+// 返回值：1 (1允许 0拒绝)  2剩余配额  3距离配额完全恢复的时间(微秒)  4需要等待的时间(微秒，-1 表示永远无法满足)
+// 时间取自 redis TIME，精确到微秒
+
+// KEYS[1] 为 hash，s 窗口开始时间，c 窗口内已使用的配额
+var _fixedWindowCmd = redis.NewScript(`local l,p,n=tonumber(ARGV[1]),tonumber(ARGV[2]),tonumber(ARGV[3]);` +
+	`local t=redis.call('time');local now=t[1]*1000000+t[2];local s=now-now%p;local c=0;` +
+	`if tonumber(redis.call('hget',KEYS[1],'s'))==s then c=tonumber(redis.call('hget',KEYS[1],'c')) end;` +
+	`local r=s+p-now;if n>l then return {0,l-c,r,-1} end;if c+n>l then return {0,l-c,r,r} end;` +
+	`c=c+n;redis.call('hset',KEYS[1],'s',s,'c',c);redis.call('pexpire',KEYS[1],math.ceil(r/1000));return {1,l-c,r,0}`,
+)
+
+// KEYS[1] 为 zset，score 为请求时间；同一微秒内的成员以当前数量区分
+var _slidingLogCmd = redis.NewScript(`local l,p,n=tonumber(ARGV[1]),tonumber(ARGV[2]),tonumber(ARGV[3]);` +
+	`local t=redis.call('time');local now=t[1]*1000000+t[2];` +
+	`redis.call('zremrangebyscore',KEYS[1],'-inf',now-p);local c=redis.call('zcard',KEYS[1]);local r=0;` +
+	`if c>0 then r=redis.call('zrange',KEYS[1],-1,-1,'withscores')[2]+p-now end;` +
+	`if n>l then return {0,l-c,r,-1} end;` +
+	`if c+n>l then local e=redis.call('zrange',KEYS[1],c+n-l-1,c+n-l-1,'withscores');return {0,l-c,r,e[2]+p-now} end;` +
+	`if n==0 then return {1,l-c,r,0} end;` +
+	`for i=1,n do redis.call('zadd',KEYS[1],now,string.format('%d:%d',now,c+i)) end;` +
+	`redis.call('pexpire',KEYS[1],math.ceil(p/1000));return {1,l-c-n,p,0}`,
+)
+
+// KEYS[1] 保存理论到达时间 tat
+var _gcraCmd = redis.NewScript(`local l,p,n=tonumber(ARGV[1]),tonumber(ARGV[2]),tonumber(ARGV[3]);` +
+	`local t=redis.call('time');local now=t[1]*1000000+t[2];` +
+	`local tat=tonumber(redis.call('get',KEYS[1]) or 0);if tat<now then tat=now end;` +
+	`local d=p-(tat-now);local m=math.floor(d*l/p);if n>l then return {0,m,tat-now,-1} end;` +
+	`local w=n*p-d*l;if w>0 then return {0,m,tat-now,math.ceil(w/l)} end;` +
+	`tat=tat+math.floor(n*p/l);redis.call('set',KEYS[1],string.format('%d',tat),'px',math.max(1,math.ceil((tat-now)/1000)));` +
+	`return {1,math.floor((p-(tat-now))*l/p),tat-now,0}`,
+)
+
+type redisLimiter struct {
+	rds    redis.UniversalClient
+	script *redis.Script
+}
+
+// NewRedisFixedWindowLimiter 固定窗口，窗口按 period 对齐，窗口切换时配额重置
+func NewRedisFixedWindowLimiter(rds redis.UniversalClient) Limiter {
+	return &redisLimiter{rds: rds, script: _fixedWindowCmd}
+}
+
+// NewRedisSlidingLogLimiter 滑动日志，记录每次请求时间，任意 period 长度的区间内不超过 limit
+func NewRedisSlidingLogLimiter(rds redis.UniversalClient) Limiter {
+	return &redisLimiter{rds: rds, script: _slidingLogCmd}
+}
+
+// NewRedisGCRALimiter GCRA，请求按 period/limit 的间隔平滑放行，最多允许 limit 的突发
+func NewRedisGCRALimiter(rds redis.UniversalClient) Limiter {
+	return &redisLimiter{rds: rds, script: _gcraCmd}
+}
+
+func (r *redisLimiter) Allow(ctx context.Context, key string, limit int, period time.Duration, n int) (Result, error) {
+	if err := checkLimit(limit, period, n); err != nil {
+		return Result{}, err
+	}
+
+	// 精确到微秒
+	p := period.Microseconds()
+	if p == 0 {
+		p = 1
+	}
+
+	now := time.Now()
+	result, err := r.script.Run(ctx, r.rds, []string{key},
+		limit,
+		p,
+		n,
+	).Int64Slice()
+	if err != nil {
+		return Result{}, err
+	}
+
+	retry := time.Duration(result[3]) * time.Microsecond
+	if result[3] < 0 {
+		retry = -1
+	}
+	return Result{
+		Allowed:    result[0] == 1,
+		Remaining:  int(result[1]),
+		ResetAt:    now.Add(time.Duration(result[2]) * time.Microsecond),
+		RetryAfter: retry,
+	}, nil
+}
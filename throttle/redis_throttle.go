@@ -8,8 +8,8 @@ import (
 	"github.com/redis/go-redis/v9"
 )
 
-// --入参： 1令牌桶容量 2一定时间令牌填充个数  3填充时间段  4获取令牌个数
-// --返回值：1 (0允许 1拒绝)  2剩余容量  3需要等待的时间
+// --入参： 1令牌桶容量 2一定时间令牌填充个数  3填充时间段(微秒)  4获取令牌个数
+// --返回值：1 (0允许 1拒绝)  2剩余容量  3需要等待的时间(毫秒)
 // local q,c,s,t,b,r=tonumber(ARGV[1]),tonumber(ARGV[4]),ARGV[2]/ARGV[3]
 //
 // if q<c then --初始容量小于获取令牌个数，直接拒绝
@@ -17,7 +17,8 @@ import (
 // end
 //
 // b=redis.call('hgetall', KEYS[1]) --查询令牌桶
-// t=redis.call('time')[1] --当前时间
+// t=redis.call('time') --当前时间，精确到微秒
+// t=t[1]*1000000+t[2]
 //
 // if next(b)==nil then
 //     --令牌桶为空时，初始化
@@ -26,27 +27,27 @@ import (
 //     --令牌桶不为空,计算恢复的令牌数量
 //     r=math.floor((t-b[4])*s)+b[2]
 //     if r<c then --令牌桶剩余容量不满足获取令牌个数，直接拒绝
-//         --计算满足条件需要等待的时间
-//         return {1,r,math.ceil((c-r)/s)}
+//         --计算满足条件需要等待的时间，扣除已经过去的时间
+//         return {1,r,math.ceil(((c-b[2])/s-(t-b[4]))/1000)}
 //     end
 //     r=(r>q and {q-c} or {r-c})[1]
 //     c=q-r
 // end
 //
 // redis.call('hset',KEYS[1],'q',r,'t',t)
-// redis.call('expire',KEYS[1],math.ceil(c/s))
+// redis.call('pexpire',KEYS[1],math.ceil(c/s/1000))
 // return {0,r,0}
 
 var _tokenBucketCmd = redis.NewScript(`local q,c,s,t,b,r=tonumber(ARGV[1]),tonumber(ARGV[4]),ARGV[2]/ARGV[3];` +
 	`if q<c then return {1,0,-1} end;` +
-	`b=redis.call('hgetall', KEYS[1]);t=redis.call('time')[1];` +
+	`b=redis.call('hgetall', KEYS[1]);t=redis.call('time');t=t[1]*1000000+t[2];` +
 	`if next(b)==nil then r=q-c else r=math.floor((t-b[4])*s)+b[2];if r<c then ` +
-	`return {1,r,math.ceil((c-r)/s)} end;r=(r>q and {q-c} or {r-c})[1];c=q-r end;` +
-	`redis.call('hset',KEYS[1],'q',r,'t',t);redis.call('expire',KEYS[1],math.ceil(c/s));return {0,r,0}`,
+	`return {1,r,math.ceil(((c-b[2])/s-(t-b[4]))/1000)} end;r=(r>q and {q-c} or {r-c})[1];c=q-r end;` +
+	`redis.call('hset',KEYS[1],'q',r,'t',t);redis.call('pexpire',KEYS[1],math.ceil(c/s/1000));return {0,r,0}`,
 )
 
 var (
-	errRestorePeriod = errors.New("restorePeriod must be >= 1s")
+	errRestorePeriod = errors.New("restorePeriod must be >= 1ms")
 	errNegative      = errors.New("quota, restoreQuota, acquire must be >= 0")
 )
 
@@ -65,8 +66,8 @@ func (r *redisThrottler) Throttle(
 	restorePeriod time.Duration,
 	acquire int,
 ) (throttled bool, leftQuota int, wait time.Duration, err error) {
-	// 精确到秒
-	if restorePeriod < time.Second {
+	// 精确到毫秒
+	if restorePeriod < time.Millisecond {
 		err = errRestorePeriod
 		return
 	}
@@ -82,11 +83,11 @@ func (r *redisThrottler) Throttle(
 	result, err := _tokenBucketCmd.Run(ctx, r.rds, []string{key},
 		quota,
 		restoreQuota,
-		int(restorePeriod.Seconds()),
+		restorePeriod.Microseconds(),
 		acquire,
 	).Int64Slice()
 	if err != nil {
 		return
 	}
-	return result[0] == 1, int(result[1]), time.Duration(result[2]) * time.Second, nil
+	return result[0] == 1, int(result[1]), time.Duration(result[2]) * time.Millisecond, nil
 }
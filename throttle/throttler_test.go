@@ -3,6 +3,7 @@ package throttle
 import (
 	"context"
 	"fmt"
+	"runtime"
 	"sync"
 	"sync/atomic"
 	"testing"
@@ -89,3 +90,103 @@ func TestTokenThrottler_Throttle(t *testing.T) {
 		})
 	}
 }
+
+func TestMemThrottler_Close(t *testing.T) {
+	before := runtime.NumGoroutine()
+	throttler := NewMemThrottler(10, time.Millisecond)
+	if err := throttler.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := throttler.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > before && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if n := runtime.NumGoroutine(); n > before {
+		t.Errorf("expect %d goroutines, but got %d", before, n)
+	}
+}
+
+func TestMemThrottler_MaxEntries(t *testing.T) {
+	throttler := NewMemThrottlerWithLimit(10, 0, 3)
+	defer throttler.Close()
+	ctx := context.Background()
+
+	for i := 0; i < 100; i++ {
+		_, _, _, err := throttler.Throttle(ctx, fmt.Sprintf("k%d", i), 3, 3, time.Second, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n := len(throttler.(*memThrottler).entries); n > 3 {
+			t.Fatalf("expect at most 3, but got %d", n)
+		}
+	}
+
+	deny, leftQuota, _, _ := throttler.Throttle(ctx, "k99", 3, 3, time.Second, 1)
+	if deny || leftQuota != 1 {
+		t.Errorf("expect leftQuota 1, but got %d", leftQuota)
+	}
+}
+
+func TestTokenThrottler_SubSecond(t *testing.T) {
+	limiters := []struct {
+		name      string
+		throttler TokenThrottler
+	}{
+		{"mem", initMemLimiter()},
+		{"redis", initRedisLimiter()},
+	}
+
+	for _, l := range limiters {
+		ll := l
+		t.Run(l.name, func(t *testing.T) {
+			ctx := context.Background()
+			key := "testSubSecond"
+			period := 100 * time.Millisecond
+
+			deny, leftQuota, _, err := ll.throttler.Throttle(ctx, key, 2, 1, period, 2)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if deny || leftQuota != 0 {
+				t.Errorf("expect allowed with leftQuota 0, but got %v %d", deny, leftQuota)
+			}
+
+			deny, _, wait, _ := ll.throttler.Throttle(ctx, key, 2, 1, period, 1)
+			if !deny || wait <= 0 || wait > period {
+				t.Errorf("expect denied with wait in (0, %s], but got %v %s", period, deny, wait)
+			}
+
+			time.Sleep(period + 20*time.Millisecond)
+			deny, leftQuota, wait, _ = ll.throttler.Throttle(ctx, key, 2, 1, period, 2)
+			if !deny || leftQuota != 1 {
+				t.Errorf("expect denied with leftQuota 1, but got %v %d", deny, leftQuota)
+			}
+			if wait <= 0 || wait > 80*time.Millisecond {
+				t.Errorf("expect wait in (0, 80ms], but got %s", wait)
+			}
+
+			// 拒绝不应改变令牌桶
+			deny, leftQuota, _, _ = ll.throttler.Throttle(ctx, key, 2, 1, period, 2)
+			if !deny || leftQuota != 1 {
+				t.Errorf("expect denied with leftQuota 1, but got %v %d", deny, leftQuota)
+			}
+
+			deny, leftQuota, _, _ = ll.throttler.Throttle(ctx, key, 2, 1, period, 1)
+			if deny || leftQuota != 0 {
+				t.Errorf("expect allowed with leftQuota 0, but got %v %d", deny, leftQuota)
+			}
+		})
+	}
+}
+
+func TestRedisThrottler_RestorePeriod(t *testing.T) {
+	throttler := initRedisLimiter()
+	_, _, _, err := throttler.Throttle(context.Background(), "testRestorePeriod", 1, 1, time.Microsecond, 1)
+	if err != errRestorePeriod {
+		t.Errorf("expect %v, but got %v", errRestorePeriod, err)
+	}
+}
@@ -11,7 +11,9 @@ import (
 	"runtime"
 	"strings"
 
+	"github.com/welllog/goutil/protojson"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/proto"
 )
 
 const (
@@ -29,7 +31,26 @@ type codeErr interface {
 	Code() codes.Code
 }
 
-func LoggingAndRecover(logger LevelLogger, debug bool) MiddlewareFunc {
+// LogOption 配置LoggingAndRecover
+type LogOption func(o *logOptions)
+
+type logOptions struct {
+	bodyFormatter func(req *http.Request, body []byte) string
+}
+
+// WithLogBodyFormatter 设置debug日志中请求body的格式化函数
+func WithLogBodyFormatter(fn func(req *http.Request, body []byte) string) LogOption {
+	return func(o *logOptions) {
+		o.bodyFormatter = fn
+	}
+}
+
+func LoggingAndRecover(logger LevelLogger, debug bool, opts ...LogOption) MiddlewareFunc {
+	var o logOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
 	return func(ctx context.Context, req *http.Request, writer ResponseWriter, next Handler) (err error) {
 		defer func() {
 			if p := recover(); p != nil {
@@ -50,7 +71,7 @@ func LoggingAndRecover(logger LevelLogger, debug bool) MiddlewareFunc {
 
 		var body string
 		if debug { // debug时，记录请求body
-			body, err = getBody(req)
+			body, err = getBody(req, o.bodyFormatter)
 			if err != nil {
 				return
 			}
@@ -127,7 +148,7 @@ type bytesReader interface {
 	Bytes() []byte
 }
 
-func getBody(req *http.Request) (body string, err error) {
+func getBody(req *http.Request, formatter func(req *http.Request, body []byte) string) (body string, err error) {
 	switch req.Method {
 	case http.MethodPost, http.MethodPut, http.MethodPatch:
 	default:
@@ -140,7 +161,7 @@ func getBody(req *http.Request) (body string, err error) {
 	ct, _, _ = mime.ParseMediaType(ct)
 	if ct == "application/json" || ct == "application/x-www-form-urlencoded" {
 		if sr, ok := req.Body.(bytesReader); ok {
-			body = strings.ReplaceAll(string(sr.Bytes()), "\"", "'") // 替换双引号，防止日志抓取错误
+			body = formatBody(req, sr.Bytes(), formatter)
 			return
 		}
 		var b []byte
@@ -149,8 +170,34 @@ func getBody(req *http.Request) (body string, err error) {
 			return
 		}
 		_ = req.Body.Close()
-		body = strings.ReplaceAll(string(b), "\"", "'") // 替换双引号，防止日志抓取错误
+		body = formatBody(req, b, formatter)
 		req.Body = io.NopCloser(bytes.NewBuffer(b))
 	}
 	return
 }
+
+func formatBody(req *http.Request, b []byte, formatter func(req *http.Request, body []byte) string) string {
+	if formatter != nil {
+		b = []byte(formatter(req, b))
+	}
+	return strings.ReplaceAll(string(b), "\"", "'") // 替换双引号，防止日志抓取错误
+}
+
+// RedactedBody 返回一个body格式化函数: 将json body解析为newMessage返回的proto消息后按redact脱敏输出,
+// 无法确定消息类型或解析失败时返回空字符串, 保证请求body不会未脱敏地记录到日志
+func RedactedBody(newMessage func(req *http.Request) proto.Message, redact *protojson.Redaction) func(req *http.Request, body []byte) string {
+	return func(req *http.Request, body []byte) string {
+		m := newMessage(req)
+		if m == nil {
+			return ""
+		}
+		if err := (protojson.UnmarshalOptions{DiscardUnknown: true}).Unmarshal(body, m); err != nil {
+			return ""
+		}
+		b, err := protojson.MarshalOptions{UseProtoNames: true, Redact: redact}.Marshal(m)
+		if err != nil {
+			return ""
+		}
+		return string(b)
+	}
+}
@@ -0,0 +1,89 @@
+package xgrpc
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/welllog/goutil/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestGetBody_Redacted(t *testing.T) {
+	formatter := RedactedBody(func(req *http.Request) proto.Message {
+		if req.URL.Path == "/person" {
+			return &protojson.Person{}
+		}
+		return nil
+	}, &protojson.Redaction{})
+
+	tests := []struct {
+		path   string
+		body   string
+		expect string
+	}{
+		{"/person", `{"name":"bob","age":18,"unknown":1}`, `{'name':'***','age':18}`},
+		{"/person", `{"name":`, ``},
+		{"/other", `{"name":"bob"}`, ``},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest(http.MethodPost, test.path, strings.NewReader(test.body))
+		req.Header.Set("Content-Type", "application/json")
+		body, err := getBody(req, formatter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if body != test.expect {
+			t.Errorf("expected %s, got %s", test.expect, body)
+		}
+	}
+}
+
+type bodyLogger struct {
+	body string
+}
+
+func (l *bodyLogger) Debugw(msg string, fields ...interface{}) {
+	for i := 0; i+1 < len(fields); i += 2 {
+		if fields[i] == "body" {
+			l.body = fields[i+1].(string)
+		}
+	}
+}
+func (l *bodyLogger) Infow(msg string, fields ...interface{}) {}
+func (l *bodyLogger) Warnw(msg string, fields ...interface{}) {}
+func (l *bodyLogger) Errw(msg string, fields ...interface{})  {}
+
+func TestLoggingAndRecover_BodyFormatter(t *testing.T) {
+	redacted := RedactedBody(func(req *http.Request) proto.Message {
+		return &protojson.Person{}
+	}, &protojson.Redaction{})
+
+	tests := []struct {
+		opts   []LogOption
+		expect string
+	}{
+		{nil, `{'name':'bob'}`},
+		{[]LogOption{WithLogBodyFormatter(redacted)}, `{'name':'***'}`},
+	}
+
+	// 同一进程中的两个中间件互不影响
+	for _, test := range tests {
+		logger := &bodyLogger{}
+		mid := LoggingAndRecover(logger, true, test.opts...)
+		req := httptest.NewRequest(http.MethodPost, "/person", strings.NewReader(`{"name":"bob"}`))
+		req.Header.Set("Content-Type", "application/json")
+		err := mid(context.Background(), req, nil, func(ctx context.Context, req *http.Request, writer ResponseWriter) error {
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if logger.body != test.expect {
+			t.Errorf("expected %s, got %s", test.expect, logger.body)
+		}
+	}
+}
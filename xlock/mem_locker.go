@@ -2,23 +2,47 @@ package xlock
 
 import (
 	"context"
+	"errors"
 	"sync"
 	"time"
 )
 
+// evictSamples 达到上限时随机采样查找已过期锁的 entry 数
+const evictSamples = 5
+
+var ErrTooManyLocks = errors.New("xlock: too many locks")
+
+// MemLocker 内存锁，Close 停止后台过期清理
+type MemLocker interface {
+	Locker
+	Close() error
+}
+
 type memLocker struct {
-	locks map[string]int64
-	mux   sync.Mutex
+	locks     map[string]int64
+	mux       sync.Mutex
+	maxLocks  int
+	done      chan struct{}
+	closeOnce sync.Once
 }
 
-func NewMemLocker(cap int, checkExpInterval time.Duration) Locker {
+func NewMemLocker(cap int, checkExpInterval time.Duration) MemLocker {
+	return NewMemLockerWithLimit(cap, checkExpInterval, 0)
+}
+
+// NewMemLockerWithLimit maxLocks 限制同时存在的锁数量，达到上限且采样未找到已过期的锁时，
+// 新 key 加锁返回 ErrTooManyLocks。持有中的锁不会被淘汰。不大于 0 时不限制
+func NewMemLockerWithLimit(cap int, checkExpInterval time.Duration, maxLocks int) MemLocker {
 	m := &memLocker{
-		locks: make(map[string]int64, cap),
+		locks:    make(map[string]int64, cap),
+		maxLocks: maxLocks,
+		done:     make(chan struct{}),
 	}
 
 	if checkExpInterval > 0 {
 		go func() {
 			ticker := time.NewTicker(checkExpInterval)
+			defer ticker.Stop()
 
 			for {
 				select {
@@ -31,6 +55,8 @@ func NewMemLocker(cap int, checkExpInterval time.Duration) Locker {
 						}
 					}
 					m.mux.Unlock()
+				case <-m.done:
+					return
 				}
 			}
 		}()
@@ -46,6 +72,10 @@ func (m *memLocker) TryLock(ctx context.Context, key string, ttl time.Duration)
 	m.mux.Lock()
 	at, ok := m.locks[key]
 	if !ok {
+		if !m.evict(now.UnixNano()) {
+			m.mux.Unlock()
+			return nil, ErrTooManyLocks
+		}
 		m.locks[key] = expAt
 		m.mux.Unlock()
 
@@ -78,3 +108,31 @@ func (m *memLocker) del(key string, expAt int64) {
 	}
 	m.mux.Unlock()
 }
+
+// Close 停止后台过期清理，可重复调用
+func (m *memLocker) Close() error {
+	m.closeOnce.Do(func() {
+		close(m.done)
+	})
+	return nil
+}
+
+// evict 在锁数量达到上限时随机采样删除一个已过期的锁，没有空位时返回 false
+func (m *memLocker) evict(timestamp int64) bool {
+	if m.maxLocks <= 0 || len(m.locks) < m.maxLocks {
+		return true
+	}
+
+	var n int
+	for k, v := range m.locks {
+		if v < timestamp {
+			delete(m.locks, k)
+			return true
+		}
+		n++
+		if n >= evictSamples {
+			break
+		}
+	}
+	return false
+}
@@ -2,6 +2,7 @@ package xlock
 
 import (
 	"context"
+	"runtime"
 	"sync"
 	"sync/atomic"
 	"testing"
@@ -74,3 +75,51 @@ func TestMemLocker_TryLock(t *testing.T) {
 		t.Errorf("lock should be removed")
 	}
 }
+
+func TestMemLocker_Close(t *testing.T) {
+	before := runtime.NumGoroutine()
+	locker := NewMemLocker(10, time.Millisecond)
+	if err := locker.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := locker.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > before && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if n := runtime.NumGoroutine(); n > before {
+		t.Errorf("expect %d goroutines, but got %d", before, n)
+	}
+}
+
+func TestMemLocker_MaxLocks(t *testing.T) {
+	locker := NewMemLockerWithLimit(10, 0, 2)
+	defer locker.Close()
+	ctx := context.Background()
+
+	l1, _ := locker.TryLock(ctx, "k1", time.Second)
+	l2, _ := locker.TryLock(ctx, "k2", time.Millisecond)
+	if l1 == nil || l2 == nil {
+		t.Fatal("lock should be success")
+	}
+
+	time.Sleep(2 * time.Millisecond)
+	l3, err := locker.TryLock(ctx, "k3", time.Second)
+	if err != nil || l3 == nil {
+		t.Fatalf("lock should replace expired lock, got %v", err)
+	}
+
+	_, err = locker.TryLock(ctx, "k4", time.Second)
+	if err != ErrTooManyLocks {
+		t.Errorf("expect %v, but got %v", ErrTooManyLocks, err)
+	}
+
+	l1.Unlock()
+	l4, err := locker.TryLock(ctx, "k4", time.Second)
+	if err != nil || l4 == nil {
+		t.Errorf("lock should be success, got %v", err)
+	}
+}